@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jeffchannell/mines-server/mines"
+)
+
+// blobTTL is how long a client can hold a blob before it is forgotten
+const blobTTL = 24 * time.Hour
+
+// errStaleBlob for a blob that was resumed already, so belongs to a turn
+// since played, or was held too long
+var errStaleBlob = errors.New("blob has already been resumed or has expired")
+
+var (
+	// blobs issued and not yet resumed, by hash, and when each expires
+	blobs   = make(map[[32]byte]time.Time)
+	blobsMu sync.Mutex
+)
+
+// issueBlob sealing g for the client to hold, remembered so it can be resumed
+// once
+func issueBlob(g *mines.Game) (string, error) {
+	blob, err := g.SignedExport(secret)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	blobsMu.Lock()
+	defer blobsMu.Unlock()
+	// forget expired blobs as we go
+	for k, expires := range blobs {
+		if now.After(expires) {
+			delete(blobs, k)
+		}
+	}
+	blobs[sha256.Sum256([]byte(blob))] = now.Add(blobTTL)
+	return blob, nil
+}
+
+// openBlob continues the game sealed in a blob still waiting to be resumed,
+// which spendBlob must then mark as used
+func openBlob(blob string) (*mines.Game, [32]byte, error) {
+	key := sha256.Sum256([]byte(blob))
+	g, err := mines.VerifiedImport(blob, secret)
+	if err != nil {
+		return nil, key, err
+	}
+	blobsMu.Lock()
+	defer blobsMu.Unlock()
+	if expires, ok := blobs[key]; !ok || time.Now().After(expires) {
+		return nil, key, errStaleBlob
+	}
+	return g, key, nil
+}
+
+// spendBlob so it can't be resumed again, reporting false if another
+// request spent it first
+func spendBlob(key [32]byte) bool {
+	blobsMu.Lock()
+	defer blobsMu.Unlock()
+	if _, ok := blobs[key]; !ok {
+		return false
+	}
+	delete(blobs, key)
+	return true
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/jeffchannell/mines-server/mines"
+)
+
+func TestBlobResume(t *testing.T) {
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}})
+	w := request(mux, "POST", "/games/"+uid+"/blob", url.Values{})
+	if http.StatusAccepted != w.Code {
+		t.Fatalf("blob got %d: %s", w.Code, w.Body.String())
+	}
+	blob, _ := decode(t, w)["blob"].(string)
+	// the game is handed over, so is no longer stored here
+	if w = request(mux, "GET", "/games/"+uid, nil); http.StatusNotFound != w.Code {
+		t.Fatalf("handed over game got %d", w.Code)
+	}
+	w = request(mux, "POST", "/games/resume", url.Values{"blob": {blob}, "x": {"4"}, "y": {"4"}})
+	if http.StatusAccepted != w.Code {
+		t.Fatalf("resume got %d: %s", w.Code, w.Body.String())
+	}
+	obj := decode(t, w)
+	if state, _ := obj["state"].(map[string]interface{}); 1.0 != state["clicks"] {
+		t.Fatalf("resumed state %v", obj["state"])
+	}
+	// the blob handed back carries the move on, and the old one is spent
+	if next, _ := obj["blob"].(string); "" == next || next == blob {
+		t.Fatalf("resume handed back %q", next)
+	}
+	if w = request(mux, "POST", "/games/resume", url.Values{"blob": {blob}}); http.StatusConflict != w.Code {
+		t.Fatalf("replayed blob got %d", w.Code)
+	}
+	tampered := []byte(obj["blob"].(string))
+	tampered[len(tampered)/2] ^= 1
+	if w = request(mux, "POST", "/games/resume", url.Values{"blob": {string(tampered)}}); http.StatusBadRequest != w.Code {
+		t.Fatalf("tampered blob got %d", w.Code)
+	}
+	if w = request(mux, "POST", "/games/resume", url.Values{"blob": {obj["blob"].(string)}, "x": {"1"}}); http.StatusBadRequest != w.Code {
+		t.Fatalf("resume without y got %d", w.Code)
+	}
+}
+
+func TestBlobLossIsConcealed(t *testing.T) {
+	mux := testMux()
+	g, err := mines.NewGameFromLayout(9, 9, beginnerLayout())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = g.ClickTile(1, 1, false); err != nil {
+		t.Fatal(err)
+	}
+	blob, err := issueBlob(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := request(mux, "POST", "/games/resume", url.Values{"blob": {blob}, "x": {"3"}, "y": {"0"}})
+	obj := decode(t, w)
+	state, _ := obj["state"].(map[string]interface{})
+	if http.StatusAccepted != w.Code || nil == state["detonated"] || "" != obj["blob"] {
+		t.Fatalf("losing resume got %d: %v", w.Code, obj)
+	}
+	// only the mine that went off is shown, keeping the rest of the layout
+	var shown int
+	for _, s := range state["tiles"].([]interface{}) {
+		if mines.DefaultSymbols.Mine == s {
+			shown++
+		}
+	}
+	if 1 != shown {
+		t.Fatalf("lost blob shows %d mines", shown)
+	}
+}
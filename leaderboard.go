@@ -0,0 +1,66 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jeffchannell/mines-server/mines"
+)
+
+// leaderboardEntry for a single won game
+type leaderboardEntry struct {
+	Name       string    `json:"name,omitempty"`
+	Duration   int64     `json:"duration_ms"`
+	BoardValue int       `json:"3bv"`
+	EndedAt    time.Time `json:"ended_at"`
+}
+
+// leaderboard of the fastest wins per difficulty preset
+type leaderboard struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string][]leaderboardEntry
+}
+
+// newLeaderboard keeping the top size entries per difficulty
+func newLeaderboard(size int) *leaderboard {
+	return &leaderboard{
+		size:    size,
+		entries: make(map[string][]leaderboardEntry),
+	}
+}
+
+// record a won game as it ends, ignoring custom boards and any the player
+// could have known in advance
+func (l *leaderboard) record(g *mines.Game) {
+	difficulty := g.Difficulty()
+	if !g.Won() || !g.Ranked() || "custom" == difficulty {
+		return
+	}
+	e := leaderboardEntry{
+		Name:       g.PlayerName(),
+		Duration:   int64(g.Duration() / time.Millisecond),
+		BoardValue: g.BoardValue(),
+		EndedAt:    time.Now(),
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries := append(l.entries[difficulty], e)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Duration < entries[j].Duration
+	})
+	if l.size < len(entries) {
+		entries = entries[:l.size]
+	}
+	l.entries[difficulty] = entries
+}
+
+// top entries for a difficulty, fastest first
+func (l *leaderboard) top(difficulty string) []leaderboardEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries := make([]leaderboardEntry, len(l.entries[difficulty]))
+	copy(entries, l.entries[difficulty])
+	return entries
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/jeffchannell/mines-server/mines"
+)
+
+// beginner board with its mines along the top row and one more below
+func beginnerLayout() [][2]uint16 {
+	layout := make([][2]uint16, 0, 10)
+	for x := uint16(0); x < 9; x++ {
+		layout = append(layout, [2]uint16{x, 0})
+	}
+	return append(layout, [2]uint16{0, 1})
+}
+
+// win a game by opening its center, then every safe tile left closed
+func win(t *testing.T, g *mines.Game) {
+	t.Helper()
+	if err := g.ClickTile(g.Width()/2, g.Height()/2, false); err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range g.Solution() {
+		x, y := uint16(i%int(g.Width())), uint16(i/int(g.Width()))
+		if tile, _ := g.Tile(x, y); 9 == v || tile.Clicked {
+			continue
+		}
+		if err := g.ClickTile(x, y, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if "won" != g.Status() {
+		t.Fatalf("game is %s, want won", g.Status())
+	}
+}
+
+// rankedBeginner game, dealt unseen as the server would
+func rankedBeginner(t *testing.T) *mines.Game {
+	t.Helper()
+	g, err := mines.NewGame(9, 9, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// roomyScores lets every win of the test onto the server leaderboard, however
+// many other tests have filled it with faster ones
+func roomyScores(t *testing.T) {
+	scores.mu.Lock()
+	size := scores.size
+	scores.size = 1 << 20
+	scores.mu.Unlock()
+	t.Cleanup(func() {
+		scores.mu.Lock()
+		scores.size = size
+		scores.mu.Unlock()
+	})
+}
+
+func TestLeaderboardRecordsWinOnce(t *testing.T) {
+	roomyScores(t)
+	before := len(scores.top("beginner"))
+	g := rankedBeginner(t)
+	win(t, g)
+	if before+1 != len(scores.top("beginner")) {
+		t.Fatal("win not recorded")
+	}
+	// moves sent after the win must not score it again
+	g.ApplyMoves([]mines.Move{{X: 4, Y: 4}})
+	g.ClickTile(4, 4, false)
+	g.End(true)
+	if before+1 != len(scores.top("beginner")) {
+		t.Fatal("win recorded twice")
+	}
+}
+
+func TestLeaderboardIgnoresLossesCustomAndUnranked(t *testing.T) {
+	l := newLeaderboard(2)
+	lost := rankedBeginner(t)
+	lost.ClickTile(4, 4, false)
+	for i, v := range lost.Solution() {
+		if 9 == v {
+			lost.ClickTile(uint16(i%9), uint16(i/9), false)
+			break
+		}
+	}
+	custom, _ := mines.NewGame(5, 5, 3)
+	win(t, custom)
+	// the player placed the mines, or has seen the board before
+	layout, _ := mines.NewGameFromLayout(9, 9, beginnerLayout())
+	win(t, layout)
+	code, _ := layout.Export()
+	imported, _ := mines.ImportGame(code)
+	win(t, imported)
+	restarted, _ := layout.Restart()
+	win(t, restarted)
+	seeded, _ := mines.NewGameWithOptions(9, 9, 10, mines.Options{Seed: 7})
+	win(t, seeded)
+	for _, g := range []*mines.Game{lost, custom, layout, imported, restarted, seeded} {
+		l.record(g)
+	}
+	if 0 != len(l.top("beginner")) || 0 != len(l.top("custom")) {
+		t.Fatalf("recorded %v", l.top("beginner"))
+	}
+}
+
+func TestLeaderboardKeepsFastest(t *testing.T) {
+	l := newLeaderboard(2)
+	for i := 0; i < 3; i++ {
+		g := rankedBeginner(t)
+		win(t, g)
+		l.record(g)
+	}
+	entries := l.top("beginner")
+	if 2 != len(entries) {
+		t.Fatalf("kept %d entries, want 2", len(entries))
+	}
+	if entries[1].Duration < entries[0].Duration {
+		t.Fatal("entries are not fastest first")
+	}
+}
+
+// winByRequest clicks every safe tile of a stored game through mux
+func winByRequest(t *testing.T, mux http.Handler, uid string) {
+	t.Helper()
+	g, err := getGameByUUIDString(uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	click := func(x, y uint16) {
+		form := url.Values{"x": {strconv.Itoa(int(x))}, "y": {strconv.Itoa(int(y))}}
+		if w := request(mux, "POST", "/games/"+uid, form); http.StatusAccepted != w.Code {
+			t.Fatalf("click got %d: %s", w.Code, w.Body.String())
+		}
+	}
+	click(g.Width()/2, g.Height()/2)
+	g.Lock()
+	solution := g.Solution()
+	g.Unlock()
+	for i, v := range solution {
+		x, y := uint16(i%int(g.Width())), uint16(i/int(g.Width()))
+		g.Lock()
+		tile, _ := g.Tile(x, y)
+		g.Unlock()
+		if 9 != v && !tile.Clicked {
+			click(x, y)
+		}
+	}
+}
+
+// onLeaderboard reports if name has a beginner entry
+func onLeaderboard(t *testing.T, mux http.Handler, name string) bool {
+	t.Helper()
+	w := request(mux, "GET", "/leaderboard?difficulty=beginner", nil)
+	if http.StatusOK != w.Code {
+		t.Fatalf("leaderboard got %d: %s", w.Code, w.Body.String())
+	}
+	for _, e := range decode(t, w)["entries"].([]interface{}) {
+		if name == e.(map[string]interface{})["name"] {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLeaderboardEndpoint(t *testing.T) {
+	roomyScores(t)
+	mux := testMux()
+	if w := request(mux, "GET", "/leaderboard?difficulty=impossible", nil); http.StatusBadRequest != w.Code {
+		t.Fatalf("unknown difficulty got %d", w.Code)
+	}
+	uid := createGame(t, mux, url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}, "name": {"winner"}})
+	winByRequest(t, mux, uid)
+	if !onLeaderboard(t, mux, "winner") {
+		t.Fatal("ranked win missing from the leaderboard")
+	}
+	// a board the client brought can't rank
+	board, _ := mines.NewGameFromLayout(9, 9, beginnerLayout())
+	code, _ := board.Export()
+	w := request(mux, "POST", "/games/import", url.Values{"code": {code}, "name": {"importer"}})
+	if http.StatusCreated != w.Code {
+		t.Fatalf("import got %d: %s", w.Code, w.Body.String())
+	}
+	uid, _ = decode(t, w)["uuid"].(string)
+	winByRequest(t, mux, uid)
+	if onLeaderboard(t, mux, "importer") {
+		t.Fatal("imported win is on the leaderboard")
+	}
+}
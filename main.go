@@ -1,88 +1,660 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"math"
+	"math/rand"
+	"mime"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jeffchannell/mines-server/mines"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// maxBodyDefault is the default request body limit, in bytes
+const maxBodyDefault = 1 << 20
+
 var (
-	games map[uuid.UUID]*mines.Game
+	scores      *leaderboard
+	startedAt   time.Time
+	corsOrigins []string
+	maxBody     int64
+	// debugToken unlocks the debug endpoint, which is off when empty
+	debugToken = os.Getenv("MINES_SERVER_DEBUG_TOKEN")
+	// maxBatch of games created by one request
+	maxBatch = 100
+	// maxDensity of mines a board can have without being forced
+	maxDensity = 0.5
+	// board made when the client doesn't say
+	defaultWidth  uint16 = 12
+	defaultHeight uint16 = 12
+	defaultMines  uint16 = 20
 )
 
 func init() {
-	games = make(map[uuid.UUID]*mines.Game)
+	// get leaderboard size
+	size, err := strconv.ParseInt(os.Getenv("MINES_SERVER_LEADERBOARD_SIZE"), 10, 32)
+	if (err != nil) || (1 > size) {
+		size = 10
+	}
+	scores = newLeaderboard(int(size))
+	mines.OnEnd(scores.record)
+	// get board limits
+	if v, err := strconv.ParseUint(os.Getenv("MINES_SERVER_MAX_WIDTH"), 10, 16); err == nil {
+		mines.MaxWidth = uint16(v)
+	}
+	if v, err := strconv.ParseUint(os.Getenv("MINES_SERVER_MAX_HEIGHT"), 10, 16); err == nil {
+		mines.MaxHeight = uint16(v)
+	}
+	if v, err := strconv.ParseUint(os.Getenv("MINES_SERVER_MAX_MINES"), 10, 16); err == nil {
+		mines.MaxMines = uint16(v)
+	}
+	// get the house default board, keeping 12x12 with 20 mines unless the
+	// configured board is one we could actually make
+	dw, errW := strconv.ParseUint(os.Getenv("MINES_SERVER_DEFAULT_WIDTH"), 10, 16)
+	dh, errH := strconv.ParseUint(os.Getenv("MINES_SERVER_DEFAULT_HEIGHT"), 10, 16)
+	dm, errM := strconv.ParseUint(os.Getenv("MINES_SERVER_DEFAULT_MINES"), 10, 16)
+	if errW == nil || errH == nil || errM == nil {
+		if errW == nil {
+			defaultWidth = uint16(dw)
+		}
+		if errH == nil {
+			defaultHeight = uint16(dh)
+		}
+		if errM == nil {
+			defaultMines = uint16(dm)
+		}
+		if _, err := mines.NewGame(defaultWidth, defaultHeight, defaultMines); err != nil {
+			log.Printf("invalid default board: %s", err)
+			defaultWidth, defaultHeight, defaultMines = 12, 12, 20
+		}
+	}
+	// get request body limit
+	maxBody, err = strconv.ParseInt(os.Getenv("MINES_SERVER_MAX_BODY"), 10, 64)
+	if (err != nil) || (1 > maxBody) {
+		maxBody = maxBodyDefault
+	}
+	// get the batch create limit
+	if v, err := strconv.ParseInt(os.Getenv("MINES_SERVER_MAX_BATCH"), 10, 32); err == nil && 0 < v {
+		maxBatch = int(v)
+	}
+	// get the density that needs forcing
+	if v, err := strconv.ParseFloat(os.Getenv("MINES_SERVER_MAX_DENSITY"), 64); err == nil && 0 < v && 1 > v {
+		maxDensity = v
+	}
+	// get the stored games limit, and what to do once it is reached
+	if v, err := strconv.ParseInt(os.Getenv("MINES_SERVER_MAX_GAMES"), 10, 32); err == nil && 0 < v {
+		maxGames = int(v)
+	}
+	evictFinished = "evict" == os.Getenv("MINES_SERVER_MAX_GAMES_POLICY")
+	// get allowed cors origins, any origin when unset
+	for _, origin := range strings.Split(os.Getenv("MINES_SERVER_CORS_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); "" != origin {
+			corsOrigins = append(corsOrigins, origin)
+		}
+	}
+}
+
+// setCORSOrigin allows the request origin if it is configured
+func setCORSOrigin(w http.ResponseWriter, r *http.Request) {
+	if 0 == len(corsOrigins) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	w.Header().Add("Vary", "Origin")
+	origin := r.Header.Get("Origin")
+	for _, allowed := range corsOrigins {
+		if origin == allowed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			return
+		}
+	}
 }
 
 func jsonError(w http.ResponseWriter, code int, err error) {
-	jsonErrorString(w, code, err.Error())
+	obj := map[string]interface{}{"error": err.Error()}
+	// tell the client how many mines would have fit
+	var tooMany *mines.TooManyMinesError
+	if errors.As(err, &tooMany) {
+		obj["max_mines"] = tooMany.Max
+	}
+	writeJSONError(w, code, obj)
+}
+
+// clickStatus for a move the game refused, telling clients that came too
+// fast to slow down
+func clickStatus(err error) int {
+	if errors.Is(err, mines.ErrTooFast) {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusBadRequest
+}
+
+// named makes a move with the player renamed as the request asks, before the
+// move so the name is there if it ends the game. A move that fails keeps the
+// old name, as nothing was played under the new one.
+func named(game *mines.Game, fields fieldValues, move func() error) error {
+	name := fields.string("name", "")
+	if "" == name {
+		return move()
+	}
+	before := game.PlayerName()
+	game.SetPlayerName(name)
+	err := move()
+	if err != nil {
+		game.SetPlayerName(before)
+	}
+	return err
 }
 
 func jsonErrorString(w http.ResponseWriter, code int, errStr string) {
+	writeJSONError(w, code, map[string]interface{}{"error": errStr})
+}
+
+func writeJSONError(w http.ResponseWriter, code int, obj map[string]interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
-	obj := make(map[string]string)
-	obj["error"] = errStr
 	json, e := json.Marshal(obj)
 	if e != nil {
-		log.Printf(e.Error())
+		log.Print(e)
 		return
 	}
-	fmt.Fprintf(w, string(json))
+	w.Write(json)
 }
 
-func main() {
+// parseForm within the body size limit, writing any error to the client
+func parseForm(w http.ResponseWriter, r *http.Request) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+	err := r.ParseForm()
+	if err == nil {
+		return true
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		jsonError(w, http.StatusRequestEntityTooLarge, err)
+	} else {
+		jsonError(w, http.StatusBadRequest, err)
+	}
+	return false
+}
+
+// viewOf the game asked for by the request query
+func viewOf(r *http.Request) (mines.View, error) {
+	q := r.URL.Query()
+	v := mines.View{
+		Flags:   "1" == q.Get("flags"),
+		Numeric: "numeric" == q.Get("format"),
+		Epoch:   "epoch" == q.Get("ts"),
+		Legacy:  "legacy" == q.Get("format"),
+		Timings: "1" == q.Get("timings"),
+	}
+	// symbols override the defaults one by one, as a JSON object
+	if symbols := q.Get("symbols"); "" != symbols {
+		set := mines.DefaultSymbols
+		if err := json.Unmarshal([]byte(symbols), &set); err != nil {
+			return v, err
+		}
+		if err := set.Check(); err != nil {
+			return v, err
+		}
+		v.Symbols = &set
+	}
+	return v, nil
+}
+
+// boardParams asked for by a create request
+type boardParams struct {
+	width, height, mines uint16
+	options              mines.Options
+}
+
+// boardOf the fields of a create request, taking defaults for absent ones
+func boardOf(v fieldValues) (b boardParams, err error) {
+	b.width = v.uint16("w", defaultWidth)
+	b.height = v.uint16("h", defaultHeight)
+	b.mines = v.uint16("m", defaultMines)
+	// or as a preset, which decides the whole board
+	if v.has("difficulty") {
+		if v.has("w") || v.has("h") || v.has("m") || v.has("density") {
+			return b, errors.New("difficulty cannot be set with w, h, m or density")
+		}
+		d := mines.Difficulties[v.string("difficulty", "")]
+		b.width, b.height, b.mines = d[0], d[1], d[2]
+	}
+	// mines can be given as a share of the board instead
+	if v.has("density") {
+		if v.has("m") {
+			return b, errors.New("m and density cannot both be set")
+		}
+		density := v.float("density", 0)
+		if !(0 < density && 1 > density) {
+			return b, errors.New("density must be between 0 and 1")
+		}
+		b.mines = mines.MinesForDensity(b.width, b.height, density)
+	}
+	// boards that can't be made at all say how many mines would fit
+	if 0 < b.mines && mines.MaxMinesFor(b.width, b.height) < b.mines {
+		return b, mines.NewTooManyMinesError(b.width, b.height, b.mines)
+	}
+	// crowded boards are more likely a typo than a challenge
+	if tiles := float64(b.width) * float64(b.height); 0 < tiles && maxDensity < float64(b.mines)/tiles && !v.bool("force", false) {
+		return b, fmt.Errorf("mine density above %g needs force=1", maxDensity)
+	}
+	b.options = mines.DefaultOptions()
+	// question marks can be disabled
+	b.options.QuestionMarks = v.bool("q", b.options.QuestionMarks)
+	// so can opening the neighbors of a satisfied number
+	b.options.AutoChord = v.bool("chord", b.options.AutoChord)
+	// unpredictable boards for competitive play
+	b.options.Secure = v.bool("secure", false)
+	// edges can wrap around
+	b.options.Wrap = v.bool("wrap", false)
+	b.options.Topology = mines.Topology(v.string("topology", ""))
+	// the first click always opens a region
+	b.options.ZeroOpening = v.bool("zero", false)
+	// joined players click in turn
+	b.options.TakeTurns = v.bool("turns", false)
+	// big openings can be revealed a piece at a time
+	b.options.CascadeLimit = int(v.uint16("cascade", 0))
+	// gentler boards keep mines from bunching up
+	b.options.NoClusters = v.bool("nocluster", false)
+	// competitive games can refuse clicks faster than a person's
+	b.options.MinInterval = time.Duration(v.uint16("min_interval", 0)) * time.Millisecond
+	// the same seed always lays out the same board
+	b.options.Seed = v.int64("seed", 0)
+	return b, nil
+}
+
+// writeCreated answers a create request with the game uuid and the board it
+// was actually given, defaults and all
+func writeCreated(w http.ResponseWriter, code int, g *mines.Game) {
+	o := g.Options()
+	obj := map[string]interface{}{
+		"uuid":           g.UUID(),
+		"width":          g.Width(),
+		"height":         g.Height(),
+		"mines":          g.Mines(),
+		"density":        float64(g.Mines()) / (float64(g.Width()) * float64(g.Height())),
+		"difficulty":     g.Difficulty(),
+		"topology":       o.Topology,
+		"question_marks": o.QuestionMarks,
+		"auto_chord":     o.AutoChord,
+		"secure":         o.Secure,
+		"wrap":           o.Wrap,
+		"zero":           o.ZeroOpening,
+		"turns":          o.TakeTurns,
+		"cascade":        o.CascadeLimit,
+		"min_interval":   int64(o.MinInterval / time.Millisecond),
+		"nocluster":      o.NoClusters,
+	}
+	// only the caller's own seed, as the one drawn for any other board would
+	// give its layout away before a click
+	if 0 != o.Seed {
+		obj["seed"] = o.Seed
+	}
+	// seeded boards are only safe to open in one place
+	if c, ok := g.Opening(); ok {
+		obj["opening"] = c
+	}
+	b, err := json.Marshal(obj)
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(b)
+}
+
+// deepCheckTimeout bounds how long a deep health check waits on a board
+const deepCheckTimeout = 2 * time.Second
+
+// checkGeneration lays out and discards a small board from each source of
+// mines, reporting how long it took, or why it couldn't
+func checkGeneration(ctx context.Context, timeout time.Duration) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	start := time.Now()
+	go func() {
+		// a broken source may panic rather than fail
+		defer func() {
+			if p := recover(); nil != p {
+				done <- fmt.Errorf("board generation panicked: %v", p)
+			}
+		}()
+		for _, secure := range []bool{false, true} {
+			o := mines.DefaultOptions()
+			o.Secure = secure
+			g, err := mines.NewGameWithContext(ctx, 9, 9, 10, o)
+			if err == nil {
+				err = g.ClickTileContext(ctx, 4, 4, false)
+			}
+			if err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	select {
+	case err := <-done:
+		return time.Since(start), err
+	case <-ctx.Done():
+		return time.Since(start), errors.New("board generation timed out")
+	}
+}
+
+// isJSON reports if the request body is JSON
+func isJSON(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return "application/json" == mediaType
+}
+
+// parseJSONForm reads a flat JSON object body into the request form, so
+// handlers can treat it like form fields, writing any error to the client
+func parseJSONForm(w http.ResponseWriter, r *http.Request) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+	var obj map[string]interface{}
+	d := json.NewDecoder(r.Body)
+	// keep numbers exact, as seeds can be large
+	d.UseNumber()
+	err := d.Decode(&obj)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			jsonError(w, http.StatusRequestEntityTooLarge, err)
+		} else {
+			jsonError(w, http.StatusBadRequest, err)
+		}
+		return false
+	}
+	r.Form = r.URL.Query()
+	// report every field that can't be a form value, as parseMoves does
+	problems := make(map[string]string)
+	for k, v := range obj {
+		s, ok := formValue(v)
+		if !ok {
+			problems[k] = "is not a string, number or boolean"
+			continue
+		}
+		r.Form.Set(k, s)
+	}
+	if 0 < len(problems) {
+		writeInvalidFields(w, problems)
+		return false
+	}
+	return true
+}
+
+// formValue of a JSON value, as it would be sent in a form
+func formValue(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		if v {
+			return "1", true
+		}
+		return "0", true
+	}
+	return "", false
+}
+
+// routes of the server, registered on mux
+func routes(mux *http.ServeMux) {
 	// favicon, for browsers
-	http.HandleFunc(`/favicon.ico`, func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc(`/favicon.ico`, func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, `static/favicon.ico`)
 	})
 	// no content in root
-	http.HandleFunc(`/`, func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc(`/`, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusNoContent)
 	})
+	// liveness and readiness probes
+	mux.HandleFunc(`/healthz`, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		// a deep check proves boards can still be made
+		if "1" == r.URL.Query().Get("deep") {
+			took, err := checkGeneration(r.Context(), deepCheckTimeout)
+			if err != nil {
+				// Go quoting isn't JSON quoting, so marshal the reason
+				writeJSONError(w, http.StatusServiceUnavailable, map[string]interface{}{"status": "fail", "error": err.Error()})
+				return
+			}
+			fmt.Fprintf(w, `{"status":"ok","games":%d,"uptime_ms":%d,"generate_us":%d}`, gameCount(), time.Since(startedAt)/time.Millisecond, took/time.Microsecond)
+			return
+		}
+		fmt.Fprintf(w, `{"status":"ok","games":%d,"uptime_ms":%d}`, gameCount(), time.Since(startedAt)/time.Millisecond)
+	})
+	// totals since the server started
+	mux.HandleFunc(`/stats`, func(w http.ResponseWriter, r *http.Request) {
+		setCORSOrigin(w, r)
+		if `GET` != r.Method {
+			jsonErrorString(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		obj := stats.snapshot()
+		var active int
+		eachGame(func(g *mines.Game) {
+			if "active" == g.Status() {
+				active++
+			}
+		})
+		obj["active"] = active
+		json, err := json.Marshal(obj)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(json)
+	})
+	// prometheus metrics
+	mux.Handle(`/metrics`, promhttp.Handler())
+	// fastest wins by difficulty
+	mux.HandleFunc(`/leaderboard`, func(w http.ResponseWriter, r *http.Request) {
+		setCORSOrigin(w, r)
+		if `GET` != r.Method {
+			jsonErrorString(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		difficulty := r.URL.Query().Get("difficulty")
+		if _, ok := mines.Difficulties[difficulty]; !ok {
+			jsonErrorString(w, http.StatusBadRequest, "unknown difficulty")
+			return
+		}
+		obj := make(map[string]interface{})
+		obj["difficulty"] = difficulty
+		obj["entries"] = scores.top(difficulty)
+		json, err := json.Marshal(obj)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(json)
+	})
+	// preview a board without creating a game
+	mux.HandleFunc(`/board-stats`, func(w http.ResponseWriter, r *http.Request) {
+		setCORSOrigin(w, r)
+		if `GET` != r.Method {
+			jsonErrorString(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		if !parseForm(w, r) {
+			return
+		}
+		fields, ok := parseFields(w, r, boardStatsFields)
+		if !ok {
+			return
+		}
+		board, err := boardOf(fields)
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, err)
+			return
+		}
+		// an unseeded preview still needs a seed to be worth anything
+		for 0 == board.options.Seed {
+			board.options.Seed = rand.Int63()
+		}
+		// the game is never stored, so it is gone after the response
+		game, err := mines.NewGameWithOptions(board.width, board.height, board.mines, board.options)
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, err)
+			return
+		}
+		obj := make(map[string]interface{})
+		obj["width"] = board.width
+		obj["height"] = board.height
+		obj["mines"] = board.mines
+		obj["seed"] = board.options.Seed
+		obj["3bv"] = game.BoardValue()
+		obj["no_guess"] = game.NoGuess(board.width/2, board.height/2)
+		json, err := json.Marshal(obj)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(json)
+	})
+	// current run of wins or losses by player
+	mux.HandleFunc(`/players/`, func(w http.ResponseWriter, r *http.Request) {
+		setCORSOrigin(w, r)
+		if `GET` != r.Method {
+			jsonErrorString(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		p := strings.Split(strings.TrimPrefix(r.URL.Path, "/players/"), "/")
+		if 2 != len(p) || "" == p[0] || "streak" != p[1] {
+			jsonErrorString(w, http.StatusNotFound, "not found")
+			return
+		}
+		s, ok := streaks.get(p[0])
+		if !ok {
+			jsonErrorString(w, http.StatusNotFound, "unknown player")
+			return
+		}
+		json, err := json.Marshal(s)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(json)
+	})
+	// head to head races on the same board
+	mux.HandleFunc(`/matches/`, withLogging(func(w http.ResponseWriter, r *http.Request) {
+		setCORSOrigin(w, r)
+		id := strings.TrimPrefix(r.URL.Path, "/matches/")
+		switch {
+		case `POST` == r.Method && "" == id:
+			if !parseForm(w, r) {
+				return
+			}
+			// absent fields take defaults, but typos are an error
+			fields, ok := parseFields(w, r, matchFields)
+			if !ok {
+				return
+			}
+			board, err := boardOf(fields)
+			if err != nil {
+				jsonError(w, http.StatusBadRequest, err)
+				return
+			}
+			mt, err := newMatch(board)
+			if errors.Is(err, errStoreFull) {
+				jsonError(w, http.StatusServiceUnavailable, err)
+				return
+			} else if err != nil {
+				jsonError(w, http.StatusBadRequest, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"id":"%s","games":["%s","%s"]}`, mt.id, mt.games[0], mt.games[1])
+		case `GET` == r.Method && "" != id:
+			mt, err := getMatchByUUIDString(id)
+			if err != nil {
+				jsonError(w, http.StatusNotFound, err)
+				return
+			}
+			obj := make(map[string]interface{})
+			obj["id"] = mt.id
+			obj["games"] = mt.games
+			// the seed gives the board away, so only share it once decided
+			if winner := mt.winner(); uuid.Nil != winner {
+				obj["winner"] = winner
+				obj["seed"] = mt.seed
+			}
+			json, err := json.Marshal(obj)
+			if err != nil {
+				jsonError(w, http.StatusInternalServerError, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(json)
+		default:
+			jsonErrorString(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	}))
 	// handle /games routes
-	http.HandleFunc(`/games/`, func(w http.ResponseWriter, r *http.Request) {
+	gamesHandler := withGzip(func(w http.ResponseWriter, r *http.Request) {
 		// add cors headers
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+		setCORSOrigin(w, r)
 		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Origin, X-GAME-UUID")
+		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Idempotency-Key, Origin, X-GAME-UUID")
 		w.Header().Set("Access-Control-Max-Age", "86400")
 		// break up the path
 		p := strings.Split(strings.TrimPrefix(r.URL.Path, "/games/"), "/")
+		// clicks and state can name the game by header instead of path
+		if header := r.Header.Get("X-GAME-UUID"); "" != header && (`GET` == r.Method || `POST` == r.Method) {
+			// words like batch and import aren't games, so can't mismatch
+			if "" == p[0] {
+				p[0] = header
+			} else if _, err := uuid.Parse(p[0]); err == nil && !sameUUID(p[0], header) {
+				jsonErrorString(w, http.StatusBadRequest, "X-GAME-UUID does not match path")
+				return
+			}
+		}
 		// switch by method first
 		switch r.Method {
 		case `OPTIONS`:
-			switch p[0] {
-			case "":
-				w.WriteHeader(http.StatusNoContent)
-			default:
-				_, err := getGameByUUIDString(p[0])
-				if err != nil {
-					jsonError(w, http.StatusNotFound, err)
-					return
-				}
-				w.WriteHeader(http.StatusNoContent)
-			}
+			// preflights are answered alike, so they can't probe for games
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNoContent)
 			return
 		case `DELETE`:
 			switch p[0] {
 			case "":
-				w.WriteHeader(http.StatusMethodNotAllowed)
+				jsonErrorString(w, http.StatusMethodNotAllowed, "method not allowed")
 			default:
-				game, err := getGameByUUIDString(p[0])
+				game, err := lockGame(p[0])
 				if err != nil {
 					jsonError(w, http.StatusNotFound, err)
 					return
 				}
-				game.End(false)
+				defer game.Unlock()
+				// deleting isn't giving up, so the game is dropped unended
+				// and never counts as a loss
+				deleteGame(game.UUID())
 				w.WriteHeader(http.StatusNoContent)
 			}
 			return
@@ -90,149 +662,770 @@ func main() {
 			switch p[0] {
 			case "":
 				w.Header().Set("Content-Type", "application/json")
-				fmt.Fprintf(w, `{"games":%d}`, len(games))
+				fmt.Fprintf(w, `{"games":%d}`, gameCount())
 				return
 			default:
-				game, err := getGameByUUIDString(p[0])
+				game, err := lockGame(p[0])
 				if err != nil {
 					jsonError(w, http.StatusNotFound, err)
 					return
 				}
+				defer game.Unlock()
+				// share the board once the game is over
+				if 1 < len(p) && "export" == p[1] {
+					if "active" == game.Status() {
+						jsonErrorString(w, http.StatusForbidden, "game is still active")
+						return
+					}
+					code, err := game.Export()
+					if err != nil {
+						jsonError(w, http.StatusInternalServerError, err)
+						return
+					}
+					w.Header().Set("Content-Type", "application/json")
+					fmt.Fprintf(w, `{"code":"%s"}`, code)
+					return
+				}
+				// the whole board, for development only
+				if 1 < len(p) && "debug" == p[1] {
+					token := []byte(r.Header.Get("X-Debug-Token"))
+					// without the right token, there is no such endpoint
+					if "" == debugToken || 1 != subtle.ConstantTimeCompare(token, []byte(debugToken)) {
+						jsonErrorString(w, http.StatusNotFound, "not found")
+						return
+					}
+					s, err := game.DebugJSON()
+					if err != nil {
+						jsonError(w, http.StatusInternalServerError, err)
+						return
+					}
+					w.Header().Set("Content-Type", "application/json")
+					w.Write([]byte(s))
+					return
+				}
+				// inspect a single tile
+				if 1 < len(p) && "tile" == p[1] {
+					if 4 != len(p) {
+						jsonErrorString(w, http.StatusNotFound, "tile not found")
+						return
+					}
+					x, errX := strconv.ParseUint(p[2], 10, 16)
+					y, errY := strconv.ParseUint(p[3], 10, 16)
+					if errX != nil || errY != nil {
+						jsonErrorString(w, http.StatusNotFound, "tile not found")
+						return
+					}
+					view, err := viewOf(r)
+					if err != nil {
+						jsonError(w, http.StatusBadRequest, err)
+						return
+					}
+					tile, err := game.TileView(uint16(x), uint16(y), view)
+					if err != nil {
+						jsonError(w, http.StatusNotFound, err)
+						return
+					}
+					json, err := json.Marshal(tile)
+					if err != nil {
+						jsonError(w, http.StatusInternalServerError, err)
+						return
+					}
+					w.Header().Set("Content-Type", "application/json")
+					w.Write(json)
+					return
+				}
+				// review the whole board once the game is over
+				if 1 < len(p) && "solution" == p[1] {
+					if "active" == game.Status() {
+						jsonErrorString(w, http.StatusForbidden, "game is still active")
+						return
+					}
+					// plain text boards are streamed a row at a time
+					if "text" == r.URL.Query().Get("format") {
+						w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+						if err := game.WriteSolution(w); err != nil {
+							log.Print(err)
+						}
+						return
+					}
+					obj := make(map[string]interface{})
+					obj["width"] = game.Width()
+					obj["height"] = game.Height()
+					// marshal values as numbers, not a byte string
+					tiles := make([]int, 0)
+					for _, v := range game.Solution() {
+						tiles = append(tiles, int(v))
+					}
+					obj["tiles"] = tiles
+					json, err := json.Marshal(obj)
+					if err != nil {
+						jsonError(w, http.StatusInternalServerError, err)
+						return
+					}
+					w.Header().Set("Content-Type", "application/json")
+					w.Write(json)
+					return
+				}
+				// plain text boards are streamed a row at a time
+				if 1 == len(p) && "text" == r.URL.Query().Get("format") {
+					w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+					if err := game.WriteGrid(w); err != nil {
+						log.Print(err)
+					}
+					return
+				}
+				// packed boards for clients short on bandwidth
+				if 1 == len(p) && "binary" == r.URL.Query().Get("format") {
+					w.Header().Set("Content-Type", "application/octet-stream")
+					w.Write(game.EncodeBinary())
+					return
+				}
+				view, err := viewOf(r)
+				if err != nil {
+					jsonError(w, http.StatusBadRequest, err)
+					return
+				}
 				var state string
 				if 1 < len(p) {
-					state, err = game.Turn(p[1])
+					state, err = game.TurnView(p[1], view)
 					if err != nil {
 						jsonError(w, http.StatusNotFound, err)
 						return
 					}
 				} else {
-					state, err = game.JSON()
+					// let polling clients skip unchanged states
+					if etag := game.ETag(); "" != etag {
+						w.Header().Set("ETag", etag)
+						if etag == r.Header.Get("If-None-Match") {
+							w.WriteHeader(http.StatusNotModified)
+							return
+						}
+					}
+					state, err = game.JSONView(view)
 					if err != nil {
 						jsonError(w, http.StatusInternalServerError, err)
 						return
 					}
 				}
 				w.Header().Set("Content-Type", "application/json")
-				fmt.Fprintf(w, state)
+				w.Write([]byte(state))
 				return
 			}
 		case `POST`:
 			switch p[0] {
 			// empty path - create a new game
 			case "":
-				// read the contents of POST
-				err := r.ParseForm()
+				// read the contents of POST, as JSON or a form
+				if isJSON(r) {
+					if !parseJSONForm(w, r) {
+						return
+					}
+				} else if !parseForm(w, r) {
+					return
+				}
+				fields, ok := parseFields(w, r, createFields)
+				if !ok {
+					return
+				}
+				board, err := boardOf(fields)
 				if err != nil {
 					jsonError(w, http.StatusBadRequest, err)
 					return
 				}
-				width, err := strconv.ParseUint(r.Form.Get("w"), 10, 16)
+				// generate a new game
+				game, err := mines.NewGameWithContext(r.Context(), board.width, board.height, board.mines, board.options)
 				if err != nil {
-					width = 12
+					// nobody is left to tell
+					if nil != r.Context().Err() {
+						return
+					}
+					jsonError(w, http.StatusBadRequest, err)
+					return
+				}
+				game.SetPlayerName(fields.string("name", ""))
+				// store the game in memory, unless a retry already did
+				code := http.StatusCreated
+				if key := r.Header.Get("Idempotency-Key"); "" != key {
+					var created bool
+					game, created, err = storeGameOnce(key, game)
+					if !created {
+						code = http.StatusOK
+					}
+				} else {
+					err = storeGame(game)
 				}
-				height, err := strconv.ParseUint(r.Form.Get("h"), 10, 16)
 				if err != nil {
-					height = 12
+					jsonError(w, http.StatusServiceUnavailable, err)
+					return
+				}
+				// send the new game uuid and its board back to the client
+				writeCreated(w, code, game)
+				return
+			// create many games alike, for load testing
+			case "batch":
+				if isJSON(r) {
+					if !parseJSONForm(w, r) {
+						return
+					}
+				} else if !parseForm(w, r) {
+					return
 				}
-				minecount, err := strconv.ParseUint(r.Form.Get("m"), 10, 16)
+				fields, ok := parseFields(w, r, batchFields)
+				if !ok {
+					return
+				}
+				count := int(fields.uint16("count", 0))
+				if 1 > count || maxBatch < count {
+					jsonErrorString(w, http.StatusBadRequest, fmt.Sprintf("count must be from 1 to %d", maxBatch))
+					return
+				}
+				board, err := boardOf(fields)
 				if err != nil {
-					minecount = 20
+					jsonError(w, http.StatusBadRequest, err)
+					return
 				}
-				// generate a new game
-				game, err := mines.NewGame(uint16(width), uint16(height), uint16(minecount))
+				uids := make([]uuid.UUID, 0, count)
+				for i := 0; i < count; i++ {
+					game, err := mines.NewGameWithContext(r.Context(), board.width, board.height, board.mines, board.options)
+					if err == nil {
+						err = storeGame(game)
+					}
+					if err != nil {
+						// all or nothing, so clients needn't tidy up
+						for _, uid := range uids {
+							deleteGame(uid)
+						}
+						if nil != r.Context().Err() {
+							return
+						} else if errors.Is(err, errStoreFull) {
+							jsonError(w, http.StatusServiceUnavailable, err)
+						} else {
+							jsonError(w, http.StatusBadRequest, err)
+						}
+						return
+					}
+					uids = append(uids, game.UUID())
+				}
+				json, err := json.Marshal(map[string]interface{}{"uuids": uids})
 				if err != nil {
 					jsonError(w, http.StatusInternalServerError, err)
 					return
 				}
-				uid := game.UUID()
-				// store the game in memory
-				games[uid] = game
-				// send the new game uuid back to the client
 				w.Header().Set("Content-Type", "application/json")
 				w.WriteHeader(http.StatusCreated)
-				fmt.Fprintf(w, fmt.Sprintf(`{"uuid":"%s"}`, uid.String()))
+				w.Write(json)
+				return
+			// create a new game from a shared board code
+			case "import":
+				if isJSON(r) {
+					if !parseJSONForm(w, r) {
+						return
+					}
+				} else if !parseForm(w, r) {
+					return
+				}
+				fields, ok := parseFields(w, r, importFields)
+				if !ok {
+					return
+				}
+				game, err := mines.ImportGame(fields.string("code", ""))
+				if err != nil {
+					jsonError(w, http.StatusBadRequest, err)
+					return
+				}
+				game.SetPlayerName(fields.string("name", ""))
+				// store the game in memory, unless a retry already did
+				code := http.StatusCreated
+				if key := r.Header.Get("Idempotency-Key"); "" != key {
+					var created bool
+					game, created, err = storeGameOnce(key, game)
+					if !created {
+						code = http.StatusOK
+					}
+				} else {
+					err = storeGame(game)
+				}
+				if err != nil {
+					jsonError(w, http.StatusServiceUnavailable, err)
+					return
+				}
+				// send the new game uuid and its board back to the client
+				writeCreated(w, code, game)
+				return
+			// continue a game the client holds, without storing it
+			case "resume":
+				if isJSON(r) {
+					if !parseJSONForm(w, r) {
+						return
+					}
+				} else if !parseForm(w, r) {
+					return
+				}
+				fields, ok := parseFields(w, r, resumeFields)
+				if !ok {
+					return
+				}
+				// a move is optional, so a client can check its blob, but
+				// needs both coordinates
+				if fields.has("x") != fields.has("y") {
+					missing := "x"
+					if fields.has("x") {
+						missing = "y"
+					}
+					writeInvalidFields(w, map[string]string{missing: "required"})
+					return
+				}
+				game, key, err := openBlob(fields.string("blob", ""))
+				if errors.Is(err, errStaleBlob) {
+					jsonError(w, http.StatusConflict, err)
+					return
+				} else if err != nil {
+					jsonError(w, http.StatusBadRequest, err)
+					return
+				}
+				// a refused move leaves the blob as it was, to try again
+				if fields.has("x") {
+					err = game.ClickTileContext(r.Context(), fields.uint16("x", 0), fields.uint16("y", 0), fields.bool("flag", false))
+					if err != nil {
+						jsonError(w, clickStatus(err), err)
+						return
+					}
+				}
+				// each blob is good for one turn, so a turn can't be taken
+				// back by resuming an older one
+				if !spendBlob(key) {
+					jsonError(w, http.StatusConflict, errStaleBlob)
+					return
+				}
+				s, err := game.JSONView(mines.View{Concealed: true})
+				if err != nil {
+					jsonError(w, http.StatusInternalServerError, err)
+					return
+				}
+				// an ended game has nothing left to resume
+				blob := ""
+				if "active" == game.Status() {
+					if blob, err = issueBlob(game); err != nil {
+						jsonError(w, http.StatusInternalServerError, err)
+						return
+					}
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusAccepted)
+				fmt.Fprintf(w, `{"blob":%q,"state":%s}`, blob, s)
 				return
 			// update game by UUID
 			default:
 				// find the requested game
-				game, err := getGameByUUIDString(p[0])
+				game, err := lockGame(p[0])
 				if err != nil {
 					jsonError(w, http.StatusNotFound, err)
 					return
 				}
-				// read the contents of POST
-				err = r.ParseForm()
-				if err != nil {
-					jsonError(w, http.StatusBadRequest, err)
+				defer game.Unlock()
+				// read the contents of POST, as JSON or a form, leaving the
+				// body of a batch of moves or flags to be decoded below
+				if isJSON(r) && !(1 < len(p) && ("moves" == p[1] || "flags" == p[1])) {
+					if !parseJSONForm(w, r) {
+						return
+					}
+				} else if !parseForm(w, r) {
 					return
 				}
-				// get the POSTed X value
-				xString := r.Form.Get("x")
-				if "" == xString {
-					jsonErrorString(w, http.StatusBadRequest, "x cannot be empty")
+				// every field of the action is checked up front, typos too
+				action := ""
+				if 1 < len(p) {
+					action = p[1]
+				}
+				fields, ok := parseFields(w, r, postFields(action))
+				if !ok {
 					return
 				}
-				// convert X into uint
-				xUint, err := strconv.ParseUint(xString, 10, 16)
-				if err != nil {
-					jsonError(w, http.StatusBadRequest, err)
+				// hand the game over to the client as a blob it can resume
+				// once, no longer storing it here, so the two copies can't be
+				// played apart
+				if 1 < len(p) && "blob" == p[1] {
+					blob, err := issueBlob(game)
+					if err != nil {
+						jsonError(w, http.StatusForbidden, err)
+						return
+					}
+					deleteGame(game.UUID())
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusAccepted)
+					fmt.Fprintf(w, `{"blob":%q}`, blob)
+					return
+				}
+				// make a guaranteed safe move
+				if 1 < len(p) && "auto" == p[1] {
+					var x, y uint16
+					err := named(game, fields, func() (err error) {
+						x, y, err = game.Auto()
+						return err
+					})
+					if err != nil {
+						jsonError(w, clickStatus(err), err)
+						return
+					}
+					s, err := game.JSON()
+					if err != nil {
+						jsonError(w, http.StatusInternalServerError, err)
+						return
+					}
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusAccepted)
+					fmt.Fprintf(w, `{"x":%d,"y":%d,"state":%s}`, x, y, s)
 					return
 				}
-				x := uint16(xUint)
-				// get the POSTed Y value
-				yString := r.Form.Get("y")
-				if "" == yString {
-					jsonErrorString(w, http.StatusBadRequest, "y cannot be empty")
+				// join a shared game
+				if 1 < len(p) && "join" == p[1] {
+					token, err := game.Join(fields.string("name", ""))
+					if err != nil {
+						jsonError(w, http.StatusBadRequest, err)
+						return
+					}
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusCreated)
+					fmt.Fprintf(w, `{"token":"%s"}`, token)
 					return
 				}
-				// convert Y into uint
-				yUint, err := strconv.ParseUint(yString, 10, 16)
+				// open more of a reveal cut short by the cascade limit
+				if 1 < len(p) && "continue" == p[1] {
+					if err := named(game, fields, game.ContinueReveal); err != nil {
+						jsonError(w, clickStatus(err), err)
+						return
+					}
+					s, err := game.JSON()
+					if err != nil {
+						jsonError(w, http.StatusInternalServerError, err)
+						return
+					}
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusAccepted)
+					w.Write([]byte(s))
+					return
+				}
+				// flag every provable mine
+				if 1 < len(p) && "autoflag" == p[1] {
+					var flagged [][2]uint16
+					err := named(game, fields, func() (err error) {
+						flagged, err = game.AutoFlag()
+						return err
+					})
+					if err != nil {
+						jsonError(w, clickStatus(err), err)
+						return
+					}
+					if nil == flagged {
+						flagged = [][2]uint16{}
+					}
+					s, err := game.JSON()
+					if err != nil {
+						jsonError(w, http.StatusInternalServerError, err)
+						return
+					}
+					f, err := json.Marshal(flagged)
+					if err != nil {
+						jsonError(w, http.StatusInternalServerError, err)
+						return
+					}
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusAccepted)
+					fmt.Fprintf(w, `{"flagged":%s,"state":%s}`, f, s)
+					return
+				}
+				// check a click without making it
+				if 1 < len(p) && "validate" == p[1] {
+					err := game.Validate(fields.uint16("x", 0), fields.uint16("y", 0))
+					w.Header().Set("Content-Type", "application/json")
+					if err != nil {
+						json, e := json.Marshal(map[string]interface{}{"valid": false, "reason": err.Error()})
+						if e != nil {
+							log.Print(e)
+							return
+						}
+						w.Write(json)
+						return
+					}
+					w.Write([]byte(`{"valid":true}`))
+					return
+				}
+				// give up, keeping the game for review
+				if 1 < len(p) && "forfeit" == p[1] {
+					if "active" != game.Status() {
+						jsonErrorString(w, http.StatusBadRequest, "Game is not active")
+						return
+					}
+					named(game, fields, func() error {
+						game.End(false)
+						return nil
+					})
+					s, err := game.JSON()
+					if err != nil {
+						jsonError(w, http.StatusInternalServerError, err)
+						return
+					}
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusAccepted)
+					w.Write([]byte(s))
+					return
+				}
+				// play the same board again, keeping this game for review
+				if 1 < len(p) && "restart" == p[1] {
+					if "active" == game.Status() {
+						jsonErrorString(w, http.StatusForbidden, "game is still active")
+						return
+					}
+					restarted, err := game.Restart()
+					if err != nil {
+						jsonError(w, http.StatusInternalServerError, err)
+						return
+					}
+					// a name given here is for the new game
+					if name := fields.string("name", ""); "" != name {
+						restarted.SetPlayerName(name)
+					}
+					if err := storeGame(restarted); err != nil {
+						jsonError(w, http.StatusServiceUnavailable, err)
+						return
+					}
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusCreated)
+					fmt.Fprintf(w, `{"uuid":"%s"}`, restarted.UUID().String())
+					return
+				}
+				// stop or restart the game clock
+				if 1 < len(p) && ("pause" == p[1] || "resume" == p[1]) {
+					if "pause" == p[1] {
+						err = named(game, fields, game.Pause)
+					} else {
+						err = named(game, fields, game.Resume)
+					}
+					if err != nil {
+						jsonError(w, http.StatusBadRequest, err)
+						return
+					}
+					s, err := game.JSON()
+					if err != nil {
+						jsonError(w, http.StatusInternalServerError, err)
+						return
+					}
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusAccepted)
+					w.Write([]byte(s))
+					return
+				}
+				// apply a batch of moves
+				if 1 < len(p) && "moves" == p[1] {
+					items, ok := parseMoves(w, r)
+					if !ok {
+						return
+					}
+					moves := make([]mines.Move, len(items))
+					for i, m := range items {
+						moves[i] = mines.Move{X: m.uint16("x", 0), Y: m.uint16("y", 0), Flag: m.bool("flag", false)}
+					}
+					// the name stays for any moves made before one failed
+					var applied int
+					named(game, fields, func() error {
+						applied, err = game.ApplyMoves(moves)
+						if 0 < applied {
+							return nil
+						}
+						return err
+					})
+					s, e := game.JSON()
+					if e != nil {
+						jsonError(w, http.StatusInternalServerError, e)
+						return
+					}
+					obj := make(map[string]interface{})
+					obj["applied"] = applied
+					obj["state"] = json.RawMessage(s)
+					code := http.StatusAccepted
+					if err != nil {
+						obj["error"] = err.Error()
+						code = clickStatus(err)
+					}
+					b, err := json.Marshal(obj)
+					if err != nil {
+						jsonError(w, http.StatusInternalServerError, err)
+						return
+					}
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(code)
+					w.Write(b)
+					return
+				}
+				// flag a list of tiles, reporting any that couldn't be
+				if 1 < len(p) && "flags" == p[1] {
+					var coords [][2]uint16
+					err := json.NewDecoder(r.Body).Decode(&coords)
+					if err != nil {
+						jsonError(w, http.StatusBadRequest, err)
+						return
+					}
+					var errs []error
+					err = named(game, fields, func() (err error) {
+						errs, err = game.FlagTiles(coords)
+						return err
+					})
+					if err != nil {
+						jsonError(w, clickStatus(err), err)
+						return
+					}
+					type flagResult struct {
+						X       uint16 `json:"x"`
+						Y       uint16 `json:"y"`
+						Flagged bool   `json:"flagged"`
+						Error   string `json:"error,omitempty"`
+					}
+					results := make([]flagResult, len(coords))
+					for i, c := range coords {
+						results[i] = flagResult{X: c[0], Y: c[1], Flagged: nil == errs[i]}
+						if nil != errs[i] {
+							results[i].Error = errs[i].Error()
+						}
+					}
+					s, err := game.JSON()
+					if err != nil {
+						jsonError(w, http.StatusInternalServerError, err)
+						return
+					}
+					f, err := json.Marshal(results)
+					if err != nil {
+						jsonError(w, http.StatusInternalServerError, err)
+						return
+					}
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusAccepted)
+					fmt.Fprintf(w, `{"results":%s,"state":%s}`, f, s)
+					return
+				}
+				// check the requested view before changing anything
+				view, err := viewOf(r)
 				if err != nil {
 					jsonError(w, http.StatusBadRequest, err)
 					return
 				}
-				y := uint16(yUint)
+				// get the POSTed coordinates
+				x := fields.uint16("x", 0)
+				y := fields.uint16("y", 0)
 				// are we toggling flags?
-				flag := "1" == r.Form.Get("flag")
+				flag := fields.bool("flag", false)
 
-				err = game.ClickTile(x, y, flag)
+				// joined players click with their token
+				err = named(game, fields, func() error {
+					if token := fields.string("player", ""); "" != token {
+						return game.ClickTileAs(token, x, y, flag)
+					}
+					return game.ClickTileContext(r.Context(), x, y, flag)
+				})
 				if err != nil {
-					jsonError(w, http.StatusBadRequest, err)
+					// nobody is left to tell
+					if nil != r.Context().Err() {
+						return
+					}
+					jsonError(w, clickStatus(err), err)
 					return
 				}
-				s, err := game.JSON()
+				var s string
+				if "1" == r.URL.Query().Get("delta") {
+					s, err = game.DeltaJSONView(view)
+				} else {
+					s, err = game.JSONView(view)
+				}
 				if err != nil {
 					jsonError(w, http.StatusInternalServerError, err)
 					return
 				}
 				w.Header().Set("Content-Type", "application/json")
 				w.WriteHeader(http.StatusAccepted)
-				fmt.Fprintf(w, s)
+				w.Write([]byte(s))
 				return
 			}
 		default:
-			w.WriteHeader(http.StatusMethodNotAllowed)
+			jsonErrorString(w, http.StatusMethodNotAllowed, "method not allowed")
 		}
 	})
-	// get port
-	portStr := os.Getenv("MINES_SERVER_PORT")
+	// get rate limit, off unless configured
+	rate, err := strconv.ParseFloat(os.Getenv("MINES_SERVER_RATE"), 64)
+	if (err == nil) && (0 < rate) {
+		burst, err := strconv.ParseInt(os.Getenv("MINES_SERVER_BURST"), 10, 32)
+		if err != nil {
+			burst = int64(math.Ceil(rate))
+		}
+		limiter := newRateLimiter(rate, int(burst))
+		go limiter.clean(time.Minute)
+		gamesHandler = limiter.withRateLimit(gamesHandler)
+	}
+	mux.HandleFunc(`/games/`, withLogging(gamesHandler))
+}
+
+func main() {
+	startedAt = time.Now()
+	routes(http.DefaultServeMux)
+	// get listen address
+	addr := listenAddr(os.Getenv("MINES_SERVER_ADDR"), os.Getenv("MINES_SERVER_PORT"))
+	log.Printf("Starting server on %v\n", addr)
+	// get drain timeout
+	drain, err := time.ParseDuration(os.Getenv("MINES_SERVER_DRAIN_TIMEOUT"))
+	if (err != nil) || (0 > drain) {
+		drain = 10 * time.Second
+	}
+	// start webserver, stopping on a signal
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	if err := serve(&http.Server{}, ln, sig, drain); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// serve on ln until stop, then let in-flight requests finish for up to drain
+func serve(server *http.Server, ln net.Listener, stop <-chan os.Signal, drain time.Duration) error {
+	done := make(chan struct{})
+	go func() {
+		<-stop
+		log.Printf("Shutting down, draining for up to %v\n", drain)
+		ctx, cancel := context.WithTimeout(context.Background(), drain)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Print(err)
+		}
+		close(done)
+	}()
+	if err := server.Serve(ln); err != http.ErrServerClosed {
+		return err
+	}
+	<-done
+	return nil
+}
+
+// listenAddr prefers a full address, falling back to a port on all interfaces
+func listenAddr(addr, portStr string) string {
+	if "" != addr {
+		return addr
+	}
 	port, err := strconv.ParseInt(portStr, 10, 32)
 	if (err != nil) || (1024 > port) {
 		port = 8080
 	}
-	log.Printf("Starting server on port %v\n", port)
-	portStr = fmt.Sprintf(":%d", port)
-	// start webserver
-	http.ListenAndServe(portStr, nil)
+	return fmt.Sprintf(":%d", port)
 }
 
-func getGameByUUIDString(uuidStr string) (g *mines.Game, err error) {
-	uid, err := uuid.Parse(uuidStr)
+// sameUUID reports if two strings parse to the same UUID
+func sameUUID(a, b string) bool {
+	uidA, err := uuid.Parse(a)
 	if err != nil {
-		return nil, err
+		return false
 	}
-	if g, ok := games[uid]; ok {
-		return g, nil
+	uidB, err := uuid.Parse(b)
+	if err != nil {
+		return false
 	}
-	return nil, errors.New("invalid Game")
+	return uidA == uidB
 }
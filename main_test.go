@@ -0,0 +1,958 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jeffchannell/mines-server/mines"
+)
+
+// testMux with every route registered, as main serves them
+func testMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	routes(mux)
+	return mux
+}
+
+// request to h, sending form as the body when it isn't nil
+func request(h http.Handler, method, target string, form url.Values) *httptest.ResponseRecorder {
+	body := ""
+	if nil != form {
+		body = form.Encode()
+	}
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if nil != form {
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// decode a JSON object from a response, failing the test if it isn't one
+func decode(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var obj map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &obj); err != nil {
+		t.Fatalf("%d %q: %s", w.Code, w.Body.String(), err)
+	}
+	return obj
+}
+
+// createGame from form, returning its uuid
+func createGame(t *testing.T, h http.Handler, form url.Values) string {
+	t.Helper()
+	w := request(h, "POST", "/games/", form)
+	if http.StatusCreated != w.Code {
+		t.Fatalf("create got %d: %s", w.Code, w.Body.String())
+	}
+	uid, _ := decode(t, w)["uuid"].(string)
+	return uid
+}
+
+// hiddenTile of a stored game, as form values
+func hiddenTile(t *testing.T, uid string) (x, y []string) {
+	t.Helper()
+	g, err := lockGame(uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Unlock()
+	for tx := uint16(0); tx < g.Width(); tx++ {
+		for ty := uint16(0); ty < g.Height(); ty++ {
+			if tile, _ := g.Tile(tx, ty); !tile.Clicked && !tile.Flagged {
+				return []string{strconv.Itoa(int(tx))}, []string{strconv.Itoa(int(ty))}
+			}
+		}
+	}
+	t.Fatal("no hidden tile left")
+	return nil, nil
+}
+
+func TestPlayerNameByRequest(t *testing.T) {
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}, "name": {"ann"}})
+	if obj := decode(t, request(mux, "GET", "/games/"+uid, nil)); "ann" != obj["player"] {
+		t.Fatalf("created game has player %v", obj["player"])
+	}
+	// a click can rename the player
+	w := request(mux, "POST", "/games/"+uid, url.Values{"x": {"4"}, "y": {"4"}, "name": {"bob"}})
+	if obj := decode(t, w); "bob" != obj["player"] {
+		t.Fatalf("renamed game has player %v", obj["player"])
+	}
+}
+
+func TestCreateSecure(t *testing.T) {
+	mux := testMux()
+	obj := decode(t, request(mux, "POST", "/games/", url.Values{"secure": {"1"}}))
+	if true != obj["secure"] {
+		t.Fatalf("created %v", obj)
+	}
+	if _, ok := obj["seed"]; ok {
+		t.Fatal("secure game shares a seed")
+	}
+	if w := request(mux, "POST", "/games/", url.Values{"secure": {"1"}, "seed": {"5"}}); http.StatusBadRequest != w.Code {
+		t.Fatalf("seeded secure game got %d", w.Code)
+	}
+}
+
+func TestClickDelta(t *testing.T) {
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}})
+	obj := decode(t, request(mux, "POST", "/games/"+uid+"?delta=1", url.Values{"x": {"4"}, "y": {"4"}}))
+	if _, ok := obj["tiles"]; ok {
+		t.Fatal("delta click sent the whole grid")
+	}
+	if changes, _ := obj["changes"].([]interface{}); 0 == len(changes) {
+		t.Fatalf("delta click changed nothing: %v", obj)
+	}
+}
+
+func TestETag(t *testing.T) {
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}})
+	get := func(etag string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/games/"+uid, nil)
+		if "" != etag {
+			r.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+	etag := get("").Header().Get("ETag")
+	if "" == etag {
+		t.Fatal("state has no ETag")
+	}
+	if w := get(etag); http.StatusNotModified != w.Code || 0 != w.Body.Len() {
+		t.Fatalf("unchanged state got %d %q", w.Code, w.Body.String())
+	}
+	// a rename changes the state as surely as a turn
+	seen := map[string]bool{etag: true}
+	for _, form := range []url.Values{
+		{"x": {"4"}, "y": {"4"}},
+		{"x": {"4"}, "y": {"4"}, "name": {"ann"}},
+		{"flag": {"1"}},
+	} {
+		// flag whichever tile the opening left hidden
+		if _, ok := form["flag"]; ok {
+			form["x"], form["y"] = hiddenTile(t, uid)
+		}
+		request(mux, "POST", "/games/"+uid, form)
+		w := get(etag)
+		if http.StatusOK != w.Code {
+			t.Fatalf("changed state after %v got %d", form, w.Code)
+		}
+		etag = w.Header().Get("ETag")
+		if seen[etag] {
+			t.Fatalf("ETag %s repeated after %v", etag, form)
+		}
+		seen[etag] = true
+	}
+}
+
+func TestHealthz(t *testing.T) {
+	mux := testMux()
+	createGame(t, mux, url.Values{})
+	w := request(mux, "GET", "/healthz", nil)
+	if http.StatusOK != w.Code || "application/json" != w.Header().Get("Content-Type") {
+		t.Fatalf("healthz got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	obj := decode(t, w)
+	if games, _ := obj["games"].(float64); "ok" != obj["status"] || 1 > games {
+		t.Fatalf("healthz is %v", obj)
+	}
+	if _, ok := obj["uptime_ms"]; !ok {
+		t.Fatalf("healthz is %v", obj)
+	}
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	})}
+	stop := make(chan os.Signal, 1)
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(server, ln, stop, 5*time.Second)
+	}()
+	// a request in flight when the signal comes is allowed to finish
+	answered := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			answered <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		answered <- string(b)
+	}()
+	<-started
+	stop <- syscall.SIGTERM
+	// new connections are refused once shutting down
+	for i := 0; ; i++ {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			break
+		}
+		conn.Close()
+		if 100 == i {
+			t.Fatal("still listening after the signal")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(release)
+	if got := <-answered; "done" != got {
+		t.Fatalf("in-flight request got %q", got)
+	}
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListenAddr(t *testing.T) {
+	for _, c := range [][3]string{
+		{"", "", ":8080"},
+		{"", "9000", ":9000"},
+		// privileged and bad ports fall back
+		{"", "80", ":8080"},
+		{"", "port", ":8080"},
+		{"127.0.0.1:9000", "9001", "127.0.0.1:9000"},
+	} {
+		if got := listenAddr(c[0], c[1]); c[2] != got {
+			t.Errorf("listenAddr(%q, %q) is %q, want %q", c[0], c[1], got, c[2])
+		}
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	was := maxBody
+	maxBody = 64
+	defer func() { maxBody = was }()
+	mux := testMux()
+	big := url.Values{"name": {strings.Repeat("a", 100)}}
+	if w := request(mux, "POST", "/games/", big); http.StatusRequestEntityTooLarge != w.Code {
+		t.Fatalf("large form got %d: %s", w.Code, w.Body.String())
+	}
+	r := httptest.NewRequest("POST", "/games/", strings.NewReader(`{"name":"`+strings.Repeat("a", 100)+`"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if http.StatusRequestEntityTooLarge != w.Code {
+		t.Fatalf("large JSON got %d: %s", w.Code, w.Body.String())
+	}
+	if w := request(mux, "POST", "/games/", url.Values{"w": {"9"}}); http.StatusCreated != w.Code {
+		t.Fatalf("small form got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestGameUUIDHeader(t *testing.T) {
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}})
+	other := createGame(t, mux, url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}})
+	send := func(method, target string, form url.Values, header string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("X-GAME-UUID", header)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+	if w := send("GET", "/games/", url.Values{}, uid); http.StatusOK != w.Code || uid != decode(t, w)["uuid"] {
+		t.Fatalf("header alone got %d: %s", w.Code, w.Body.String())
+	}
+	if w := send("POST", "/games/", url.Values{"x": {"4"}, "y": {"4"}}, uid); http.StatusAccepted != w.Code {
+		t.Fatalf("click by header got %d: %s", w.Code, w.Body.String())
+	}
+	if w := send("GET", "/games/"+other, url.Values{}, uid); http.StatusBadRequest != w.Code {
+		t.Fatalf("mismatched header got %d", w.Code)
+	}
+	// routes that aren't a game ignore the header
+	if w := send("POST", "/games/batch", url.Values{"count": {"2"}}, uid); http.StatusCreated != w.Code {
+		t.Fatalf("batch got %d: %s", w.Code, w.Body.String())
+	}
+	board, _ := mines.NewGameFromLayout(9, 9, [][2]uint16{{0, 0}})
+	code, _ := board.Export()
+	if w := send("POST", "/games/import", url.Values{"code": {code}}, uid); http.StatusCreated != w.Code {
+		t.Fatalf("import got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestNoContentIsJSON(t *testing.T) {
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{})
+	// preflights look alike whether or not the game exists
+	for _, c := range [][2]string{{"GET", "/"}, {"OPTIONS", "/games/"}, {"OPTIONS", "/games/" + uid}, {"OPTIONS", "/games/nope"}} {
+		w := request(mux, c[0], c[1], nil)
+		if http.StatusNoContent != w.Code || "application/json" != w.Header().Get("Content-Type") {
+			t.Errorf("%s %s got %d %q", c[0], c[1], w.Code, w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestExportAndImport(t *testing.T) {
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}, "seed": {"12"}})
+	// the board stays private while the game is played
+	if w := request(mux, "GET", "/games/"+uid+"/export", nil); http.StatusForbidden != w.Code {
+		t.Fatalf("export of an active game got %d", w.Code)
+	}
+	if w := request(mux, "POST", "/games/"+uid+"/forfeit", url.Values{}); http.StatusAccepted != w.Code {
+		t.Fatalf("forfeit got %d: %s", w.Code, w.Body.String())
+	}
+	w := request(mux, "GET", "/games/"+uid+"/export", nil)
+	if http.StatusOK != w.Code {
+		t.Fatalf("export got %d: %s", w.Code, w.Body.String())
+	}
+	code, _ := decode(t, w)["code"].(string)
+	w = request(mux, "POST", "/games/import", url.Values{"code": {code}})
+	if http.StatusCreated != w.Code {
+		t.Fatalf("import got %d: %s", w.Code, w.Body.String())
+	}
+	obj := decode(t, w)
+	if 9.0 != obj["width"] || 9.0 != obj["height"] || 10.0 != obj["mines"] {
+		t.Fatalf("imported %v", obj)
+	}
+	if w = request(mux, "POST", "/games/import", url.Values{"code": {"nope"}}); http.StatusBadRequest != w.Code {
+		t.Fatalf("import of a bad code got %d", w.Code)
+	}
+}
+
+func TestSolutionEndpoint(t *testing.T) {
+	mux := testMux()
+	board, _ := mines.NewGameFromLayout(2, 2, [][2]uint16{{1, 0}})
+	code, _ := board.Export()
+	uid, _ := decode(t, request(mux, "POST", "/games/import", url.Values{"code": {code}}))["uuid"].(string)
+	if w := request(mux, "GET", "/games/"+uid+"/solution", nil); http.StatusForbidden != w.Code {
+		t.Fatalf("solution of an active game got %d", w.Code)
+	}
+	request(mux, "POST", "/games/"+uid+"/forfeit", url.Values{})
+	obj := decode(t, request(mux, "GET", "/games/"+uid+"/solution", nil))
+	tiles, _ := obj["tiles"].([]interface{})
+	if 2.0 != obj["width"] || 4 != len(tiles) || 9.0 != tiles[1] || 1.0 != tiles[0] {
+		t.Fatalf("solution is %v", obj)
+	}
+	w := request(mux, "GET", "/games/"+uid+"/solution?format=text", nil)
+	if want := "  0 1\n0 1 9\n1 1 1\n"; want != w.Body.String() {
+		t.Fatalf("text solution is %q, want %q", w.Body.String(), want)
+	}
+}
+
+func TestCreateOverMaxDimensions(t *testing.T) {
+	mux := testMux()
+	w := request(mux, "POST", "/games/", url.Values{"w": {strconv.Itoa(int(mines.MaxWidth) + 1)}, "h": {"9"}, "m": {"10"}})
+	if http.StatusBadRequest != w.Code {
+		t.Fatalf("wide board got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestCreateTopology(t *testing.T) {
+	mux := testMux()
+	if obj := decode(t, request(mux, "POST", "/games/", url.Values{"topology": {"hex"}})); "hex" != obj["topology"] {
+		t.Fatalf("created %v", obj)
+	}
+	if w := request(mux, "POST", "/games/", url.Values{"topology": {"triangle"}}); http.StatusBadRequest != w.Code {
+		t.Fatalf("unknown topology got %d", w.Code)
+	}
+}
+
+// postJSON body to target through h
+func postJSON(h http.Handler, target, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", target, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestMovesEndpoint(t *testing.T) {
+	mux := testMux()
+	board, _ := mines.NewGameFromLayout(4, 1, [][2]uint16{{1, 0}})
+	code, _ := board.Export()
+	uid, _ := decode(t, request(mux, "POST", "/games/import", url.Values{"code": {code}}))["uuid"].(string)
+	w := postJSON(mux, "/games/"+uid+"/moves", `[{"x":3,"y":0},{"x":1,"y":0,"flag":true},{"x":5,"y":0}]`)
+	obj := decode(t, w)
+	if http.StatusBadRequest != w.Code || 2.0 != obj["applied"] || nil == obj["error"] {
+		t.Fatalf("bad move got %d: %v", w.Code, obj)
+	}
+	w = postJSON(mux, "/games/"+uid+"/moves", `[{"x":0,"y":0}]`)
+	obj = decode(t, w)
+	state, _ := obj["state"].(map[string]interface{})
+	if http.StatusAccepted != w.Code || 1.0 != obj["applied"] || true != state["won"] {
+		t.Fatalf("winning move got %d: %v", w.Code, obj)
+	}
+}
+
+func TestForfeitAndDelete(t *testing.T) {
+	mux := testMux()
+	lost := stats.snapshot()["lost"].(int)
+	uid := createGame(t, mux, url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}})
+	w := request(mux, "POST", "/games/"+uid+"/forfeit", url.Values{})
+	if obj := decode(t, w); http.StatusAccepted != w.Code || nil == obj["ended_at"] || nil != obj["won"] {
+		t.Fatalf("forfeit got %d: %s", w.Code, w.Body.String())
+	}
+	// the game is kept for review, and can't be given up twice
+	if w = request(mux, "GET", "/games/"+uid, nil); http.StatusOK != w.Code {
+		t.Fatalf("forfeited game got %d", w.Code)
+	}
+	if w = request(mux, "POST", "/games/"+uid+"/forfeit", url.Values{}); http.StatusBadRequest != w.Code {
+		t.Fatalf("second forfeit got %d", w.Code)
+	}
+	if lost+1 != stats.snapshot()["lost"].(int) {
+		t.Fatal("forfeit not counted as a loss")
+	}
+	// deleting drops a game without ending it
+	uid = createGame(t, mux, url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}})
+	if w = request(mux, "DELETE", "/games/"+uid, nil); http.StatusNoContent != w.Code {
+		t.Fatalf("delete got %d: %s", w.Code, w.Body.String())
+	}
+	if w = request(mux, "GET", "/games/"+uid, nil); http.StatusNotFound != w.Code {
+		t.Fatalf("deleted game got %d", w.Code)
+	}
+	if w = request(mux, "DELETE", "/games/"+uid, nil); http.StatusNotFound != w.Code {
+		t.Fatalf("second delete got %d", w.Code)
+	}
+	if lost+1 != stats.snapshot()["lost"].(int) {
+		t.Fatal("delete counted as a loss")
+	}
+}
+
+func TestPauseEndpoints(t *testing.T) {
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}})
+	w := request(mux, "POST", "/games/"+uid+"/pause", url.Values{})
+	if http.StatusAccepted != w.Code || true != decode(t, w)["paused"] {
+		t.Fatalf("pause got %d: %s", w.Code, w.Body.String())
+	}
+	if w = request(mux, "POST", "/games/"+uid, url.Values{"x": {"4"}, "y": {"4"}}); http.StatusBadRequest != w.Code {
+		t.Fatalf("click while paused got %d", w.Code)
+	}
+	w = request(mux, "POST", "/games/"+uid+"/resume", url.Values{})
+	if _, paused := decode(t, w)["paused"]; http.StatusAccepted != w.Code || paused {
+		t.Fatalf("resume got %d: %s", w.Code, w.Body.String())
+	}
+	if w = request(mux, "POST", "/games/"+uid+"/resume", url.Values{}); http.StatusBadRequest != w.Code {
+		t.Fatalf("second resume got %d", w.Code)
+	}
+}
+
+func TestJoinSharedGame(t *testing.T) {
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}})
+	w := request(mux, "POST", "/games/"+uid+"/join", url.Values{"name": {"ann"}})
+	token, _ := decode(t, w)["token"].(string)
+	if http.StatusCreated != w.Code || "" == token {
+		t.Fatalf("join got %d: %s", w.Code, w.Body.String())
+	}
+	w = request(mux, "POST", "/games/"+uid, url.Values{"x": {"4"}, "y": {"4"}, "player": {token}})
+	if obj := decode(t, w); http.StatusAccepted != w.Code || "ann" != obj["turn_player"] {
+		t.Fatalf("click as ann got %d: %s", w.Code, w.Body.String())
+	}
+	if w = request(mux, "POST", "/games/"+uid, url.Values{"x": {"4"}, "y": {"4"}, "player": {"nobody"}}); http.StatusBadRequest != w.Code {
+		t.Fatalf("click as nobody got %d", w.Code)
+	}
+}
+
+// invalidFields a 400 response names, failing the test if it isn't one
+func invalidFields(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	if http.StatusBadRequest != w.Code {
+		t.Fatalf("got %d, want 400: %s", w.Code, w.Body.String())
+	}
+	fields, _ := decode(t, w)["fields"].(map[string]interface{})
+	return fields
+}
+
+func TestCreateFromJSON(t *testing.T) {
+	mux := testMux()
+	w := postJSON(mux, "/games/", `{"w":9,"h":7,"m":10,"q":false,"seed":9007199254740993,"name":"ann"}`)
+	if http.StatusCreated != w.Code {
+		t.Fatalf("create got %d: %s", w.Code, w.Body.String())
+	}
+	// seeds too big for a float64 are kept exact
+	if !strings.Contains(w.Body.String(), `"seed":9007199254740993`) {
+		t.Fatalf("created %s", w.Body.String())
+	}
+	obj := decode(t, w)
+	if 9.0 != obj["width"] || 7.0 != obj["height"] || 10.0 != obj["mines"] || false != obj["question_marks"] {
+		t.Fatalf("created %v", obj)
+	}
+	for _, body := range []string{`{"w":[9]}`, `{"w":9`, `[]`} {
+		if w = postJSON(mux, "/games/", body); http.StatusBadRequest != w.Code {
+			t.Errorf("create from %s got %d", body, w.Code)
+		}
+	}
+}
+
+func TestClickFromJSON(t *testing.T) {
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}})
+	w := postJSON(mux, "/games/"+uid, `{"x":4,"y":4}`)
+	if http.StatusAccepted != w.Code || 1.0 != decode(t, w)["clicks"] {
+		t.Fatalf("click got %d: %s", w.Code, w.Body.String())
+	}
+	x, y := hiddenTile(t, uid)
+	w = postJSON(mux, "/games/"+uid, `{"x":`+x[0]+`,"y":`+y[0]+`,"flag":true}`)
+	if http.StatusAccepted != w.Code || 1.0 != decode(t, w)["flags"] {
+		t.Fatalf("flag got %d: %s", w.Code, w.Body.String())
+	}
+	if _, ok := invalidFields(t, postJSON(mux, "/games/"+uid, `{"x":"four","y":4}`))["x"]; !ok {
+		t.Fatal("bad x not reported")
+	}
+}
+
+func TestBoardStats(t *testing.T) {
+	mux := testMux()
+	count := gameCount()
+	w := request(mux, "GET", "/board-stats?w=16&h=16&m=40&seed=3", nil)
+	if http.StatusOK != w.Code {
+		t.Fatalf("board-stats got %d: %s", w.Code, w.Body.String())
+	}
+	obj := decode(t, w)
+	g, _ := mines.NewGameWithOptions(16, 16, 40, mines.Options{Seed: 3, QuestionMarks: true, AutoChord: true})
+	if float64(g.BoardValue()) != obj["3bv"] || g.NoGuess(8, 8) != obj["no_guess"] || 3.0 != obj["seed"] {
+		t.Fatalf("board-stats is %v", obj)
+	}
+	// unseeded previews say which seed they tried
+	if seed, _ := decode(t, request(mux, "GET", "/board-stats", nil))["seed"].(float64); 0 == seed {
+		t.Fatal("unseeded preview has no seed")
+	}
+	if count != gameCount() {
+		t.Fatal("preview stored a game")
+	}
+	if _, ok := invalidFields(t, request(mux, "GET", "/board-stats?secure=1", nil))["secure"]; !ok {
+		t.Fatal("secure preview not reported")
+	}
+}
+
+func TestBoardStatsDensity(t *testing.T) {
+	mux := testMux()
+	w := request(mux, "GET", "/board-stats?w=10&h=10&density=0.2&seed=3", nil)
+	if http.StatusOK != w.Code {
+		t.Fatalf("board-stats got %d: %s", w.Code, w.Body.String())
+	}
+	if float64(mines.MinesForDensity(10, 10, 0.2)) != decode(t, w)["mines"] {
+		t.Fatal("board-stats ignored density")
+	}
+	if _, ok := invalidFields(t, request(mux, "GET", "/board-stats?density=lots", nil))["density"]; !ok {
+		t.Fatal("bad density not reported")
+	}
+}
+
+func TestCreateDensity(t *testing.T) {
+	mux := testMux()
+	obj := decode(t, request(mux, "POST", "/games/", url.Values{"w": {"10"}, "h": {"10"}, "density": {"0.15"}}))
+	if 15.0 != obj["mines"] || 0.15 != obj["density"] {
+		t.Fatalf("created %v", obj)
+	}
+	for _, form := range []url.Values{{"density": {"0"}}, {"density": {"1"}}, {"density": {"0.1"}, "m": {"10"}}} {
+		if w := request(mux, "POST", "/games/", form); http.StatusBadRequest != w.Code {
+			t.Errorf("density %v got %d", form, w.Code)
+		}
+	}
+}
+
+func TestRestartEndpoint(t *testing.T) {
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}})
+	if w := request(mux, "POST", "/games/"+uid+"/restart", url.Values{}); http.StatusForbidden != w.Code {
+		t.Fatalf("restart of an active game got %d", w.Code)
+	}
+	request(mux, "POST", "/games/"+uid, url.Values{"x": {"4"}, "y": {"4"}})
+	request(mux, "POST", "/games/"+uid+"/forfeit", url.Values{})
+	w := request(mux, "POST", "/games/"+uid+"/restart", url.Values{"name": {"bob"}})
+	restarted, _ := decode(t, w)["uuid"].(string)
+	if http.StatusCreated != w.Code || uid == restarted {
+		t.Fatalf("restart got %d: %s", w.Code, w.Body.String())
+	}
+	obj := decode(t, request(mux, "GET", "/games/"+restarted, nil))
+	if "bob" != obj["player"] || 0.0 != obj["clicks"] {
+		t.Fatalf("restarted game is %v", obj)
+	}
+	// the old game is kept for review
+	if w = request(mux, "GET", "/games/"+uid, nil); http.StatusOK != w.Code {
+		t.Fatalf("restarted game got %d", w.Code)
+	}
+}
+
+func TestTextBoard(t *testing.T) {
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{"w": {"3"}, "h": {"2"}, "m": {"1"}})
+	w := request(mux, "GET", "/games/"+uid+"?format=text", nil)
+	if "text/plain; charset=utf-8" != w.Header().Get("Content-Type") || "  0 1 2\n0 ? ? ?\n1 ? ? ?\n" != w.Body.String() {
+		t.Fatalf("text board is %q %q", w.Header().Get("Content-Type"), w.Body.String())
+	}
+}
+
+func TestTileEndpoint(t *testing.T) {
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}})
+	request(mux, "POST", "/games/"+uid, url.Values{"x": {"4"}, "y": {"4"}})
+	w := request(mux, "GET", "/games/"+uid+"/tile/4/4", nil)
+	if obj := decode(t, w); true != obj["clicked"] || nil == obj["value"] {
+		t.Fatalf("revealed tile %v", obj)
+	}
+	x, y := hiddenTile(t, uid)
+	w = request(mux, "GET", "/games/"+uid+"/tile/"+x[0]+"/"+y[0], nil)
+	if obj := decode(t, w); false != obj["clicked"] || nil != obj["value"] {
+		t.Fatalf("hidden tile %v", obj)
+	}
+	for _, target := range []string{"/tile/9/0", "/tile/0/9", "/tile/a/1", "/tile/1"} {
+		if w = request(mux, "GET", "/games/"+uid+target, nil); http.StatusNotFound != w.Code {
+			t.Fatalf("%s got %d", target, w.Code)
+		}
+	}
+}
+
+func TestAutoFlagEndpoint(t *testing.T) {
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}})
+	// nothing is flagged before the board is opened
+	obj := decode(t, request(mux, "POST", "/games/"+uid+"/autoflag", nil))
+	if flagged, ok := obj["flagged"].([]interface{}); !ok || 0 != len(flagged) || nil == obj["state"] {
+		t.Fatalf("auto flag on a fresh game %v", obj)
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{})
+	// every route answers alike, whether or not the game exists
+	for _, target := range []string{"/games/", "/games/" + uid, "/games/" + uid + "/tile/0/0", "/games/00000000-0000-0000-0000-000000000000"} {
+		w := request(mux, "OPTIONS", target, nil)
+		if http.StatusNoContent != w.Code || "86400" != w.Header().Get("Access-Control-Max-Age") || "" == w.Header().Get("Access-Control-Allow-Methods") {
+			t.Fatalf("%s preflight got %d %v", target, w.Code, w.Header())
+		}
+	}
+}
+
+func TestDefaultBoard(t *testing.T) {
+	w, h, m := defaultWidth, defaultHeight, defaultMines
+	defer func() { defaultWidth, defaultHeight, defaultMines = w, h, m }()
+	defaultWidth, defaultHeight, defaultMines = 20, 10, 30
+	mux := testMux()
+	obj := decode(t, request(mux, "GET", "/games/"+createGame(t, mux, url.Values{}), nil))
+	if 20.0 != obj["width"] || 10.0 != obj["height"] || 30.0 != obj["mines"] {
+		t.Fatalf("default board %vx%v with %v mines", obj["width"], obj["height"], obj["mines"])
+	}
+	// the client's size still wins
+	obj = decode(t, request(mux, "GET", "/games/"+createGame(t, mux, url.Values{"w": {"9"}}), nil))
+	if 9.0 != obj["width"] || 10.0 != obj["height"] || 30.0 != obj["mines"] {
+		t.Fatalf("sized board %vx%v with %v mines", obj["width"], obj["height"], obj["mines"])
+	}
+}
+
+func TestValidateEndpoint(t *testing.T) {
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}, "name": {"ann"}})
+	before := request(mux, "GET", "/games/"+uid, nil).Body.String()
+	obj := decode(t, request(mux, "POST", "/games/"+uid+"/validate", url.Values{"x": {"4"}, "y": {"4"}, "name": {"bob"}}))
+	if true != obj["valid"] {
+		t.Fatalf("valid click got %v", obj)
+	}
+	// checking a click neither makes it nor renames the player
+	if after := request(mux, "GET", "/games/"+uid, nil).Body.String(); before != after {
+		t.Fatalf("validate changed the game from %s to %s", before, after)
+	}
+	if obj = decode(t, request(mux, "POST", "/games/"+uid+"/validate", url.Values{"x": {"9"}, "y": {"0"}})); false != obj["valid"] || "" == obj["reason"] {
+		t.Fatalf("off the board click got %v", obj)
+	}
+	// a click that can't be read is a bad request, not an invalid click
+	if _, ok := invalidFields(t, request(mux, "POST", "/games/"+uid+"/validate", url.Values{"x": {"0"}}))["y"]; !ok {
+		t.Fatal("missing y not reported")
+	}
+	request(mux, "POST", "/games/"+uid+"/forfeit", nil)
+	if obj = decode(t, request(mux, "POST", "/games/"+uid+"/validate", url.Values{"x": {"4"}, "y": {"4"}})); false != obj["valid"] {
+		t.Fatalf("click on an ended game is %v", obj)
+	}
+}
+
+func TestSeededOpening(t *testing.T) {
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{"w": {"9"}, "h": {"7"}, "m": {"10"}, "seed": {"3"}, "zero": {"1"}})
+	obj := decode(t, request(mux, "GET", "/games/"+uid, nil))
+	if opening, _ := obj["opening"].([]interface{}); 2 != len(opening) || 4.0 != opening[0] || 3.0 != opening[1] {
+		t.Fatalf("new seeded game opens at %v", obj["opening"])
+	}
+	w := request(mux, "POST", "/games/"+uid, url.Values{"x": {"4"}, "y": {"3"}})
+	if state := decode(t, w); nil != state["detonated"] || nil != state["opening"] {
+		t.Fatalf("opened %v", state)
+	}
+	uid = createGame(t, mux, url.Values{"w": {"9"}, "h": {"7"}, "m": {"10"}})
+	if _, ok := decode(t, request(mux, "GET", "/games/"+uid, nil))["opening"]; ok {
+		t.Fatal("unseeded game names an opening")
+	}
+}
+
+func TestSymbolsAreCapped(t *testing.T) {
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}})
+	ok := url.QueryEscape(`{"hidden":"▒"}`)
+	if w := request(mux, "GET", "/games/"+uid+"?symbols="+ok, nil); http.StatusOK != w.Code {
+		t.Fatalf("short symbol got %d: %s", w.Code, w.Body.String())
+	}
+	long := url.QueryEscape(`{"hidden":"` + strings.Repeat("x", 9) + `"}`)
+	if w := request(mux, "GET", "/games/"+uid+"?symbols="+long, nil); http.StatusBadRequest != w.Code {
+		t.Fatalf("long symbol got %d", w.Code)
+	}
+	long = url.QueryEscape(`{"numbers":["","1","2","` + strings.Repeat("3", 100) + `"]}`)
+	if w := request(mux, "POST", "/games/"+uid+"?symbols="+long, url.Values{"x": {"4"}, "y": {"4"}}); http.StatusBadRequest != w.Code {
+		t.Fatalf("long number symbol got %d", w.Code)
+	}
+}
+
+func TestNumericFormat(t *testing.T) {
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}})
+	tiles, _ := decode(t, request(mux, "GET", "/games/"+uid+"?format=numeric", nil))["tiles"].([]interface{})
+	if 81 != len(tiles) {
+		t.Fatalf("numeric board has %d tiles", len(tiles))
+	}
+	if c, _ := tiles[0].(map[string]interface{}); "hidden" != c["s"] {
+		t.Fatalf("fresh tile is %v", tiles[0])
+	}
+}
+
+func TestDebugEndpoint(t *testing.T) {
+	was := debugToken
+	defer func() { debugToken = was }()
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}})
+	request(mux, "POST", "/games/"+uid, url.Values{"x": {"4"}, "y": {"4"}})
+	debug := func(token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/games/"+uid+"/debug", nil)
+		if "" != token {
+			r.Header.Set("X-Debug-Token", token)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+	// the endpoint is off without a configured token
+	debugToken = ""
+	if w := debug(""); http.StatusNotFound != w.Code {
+		t.Fatalf("unconfigured debug got %d", w.Code)
+	}
+	debugToken = "sesame"
+	for _, token := range []string{"", "sesam", "sesame!"} {
+		if w := debug(token); http.StatusNotFound != w.Code {
+			t.Fatalf("debug with %q got %d", token, w.Code)
+		}
+	}
+	obj := decode(t, debug("sesame"))
+	if m, _ := obj["mines"].([]interface{}); 10 != len(m) || true != obj["generated"] {
+		t.Fatalf("debug board %v", obj)
+	}
+	if tiles, _ := obj["tiles"].([]interface{}); 81 != len(tiles) {
+		t.Fatalf("debug board has %d tiles", len(tiles))
+	}
+}
+
+func TestGetNewGameIsHidden(t *testing.T) {
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{"w": {"9"}, "h": {"7"}, "m": {"10"}})
+	w := request(mux, "GET", "/games/"+uid, nil)
+	if http.StatusOK != w.Code {
+		t.Fatalf("get got %d: %s", w.Code, w.Body.String())
+	}
+	obj := decode(t, w)
+	if 9.0 != obj["width"] || 7.0 != obj["height"] {
+		t.Fatalf("board is %vx%v, want 9x7", obj["width"], obj["height"])
+	}
+	tiles, _ := obj["tiles"].([]interface{})
+	if 63 != len(tiles) {
+		t.Fatalf("got %d tiles, want 63", len(tiles))
+	}
+	for i, s := range tiles {
+		if "?" != s {
+			t.Fatalf("tile %d is %q, want hidden", i, s)
+		}
+	}
+}
+
+func TestTurnEndpoint(t *testing.T) {
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}})
+	request(mux, "POST", "/games/"+uid, url.Values{"x": {"0"}, "y": {"0"}, "flag": {"1"}})
+	x, y := hiddenTile(t, uid)
+	request(mux, "POST", "/games/"+uid, url.Values{"x": x, "y": y, "flag": {"1"}})
+	if obj := decode(t, request(mux, "GET", "/games/"+uid+"/0", nil)); 1.0 != obj["flags"] || 1.0 != obj["turns"] {
+		t.Fatalf("first turn %v", obj)
+	}
+	if obj := decode(t, request(mux, "GET", "/games/"+uid+"/1", nil)); 2.0 != obj["flags"] || 2.0 != obj["turns"] {
+		t.Fatalf("second turn %v", obj)
+	}
+	for _, n := range []string{"2", "-1", "abc"} {
+		if w := request(mux, "GET", "/games/"+uid+"/"+n, nil); http.StatusNotFound != w.Code {
+			t.Fatalf("turn %s got %d", n, w.Code)
+		}
+	}
+}
+
+func TestFlagsEndpoint(t *testing.T) {
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}})
+	request(mux, "POST", "/games/"+uid, url.Values{"x": {"4"}, "y": {"4"}})
+	x, y := hiddenTile(t, uid)
+	w := postJSON(mux, "/games/"+uid+"/flags", "[["+x[0]+","+y[0]+"],[4,4],[9,0]]")
+	if http.StatusAccepted != w.Code {
+		t.Fatalf("flags got %d: %s", w.Code, w.Body.String())
+	}
+	obj := decode(t, w)
+	results, _ := obj["results"].([]interface{})
+	if 3 != len(results) {
+		t.Fatalf("flags results %v", obj["results"])
+	}
+	for i, flagged := range []bool{true, false, false} {
+		r := results[i].(map[string]interface{})
+		if flagged != r["flagged"] || flagged == (nil != r["error"]) {
+			t.Fatalf("result %d is %v", i, r)
+		}
+	}
+	if state, _ := obj["state"].(map[string]interface{}); 1.0 != state["flags"] {
+		t.Fatalf("state after flagging %v", obj["state"])
+	}
+	if w = postJSON(mux, "/games/"+uid+"/flags", `{"x":1}`); http.StatusBadRequest != w.Code {
+		t.Fatalf("flags object got %d", w.Code)
+	}
+}
+
+func TestBinaryFormat(t *testing.T) {
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}})
+	w := request(mux, "GET", "/games/"+uid+"?format=binary", nil)
+	if http.StatusOK != w.Code || "application/octet-stream" != w.Header().Get("Content-Type") {
+		t.Fatalf("binary got %d as %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	// a header, then a nibble for each of the 81 tiles
+	if b := w.Body.Bytes(); 7+41 != len(b) || 9 != b[1] || 9 != b[3] {
+		t.Fatalf("binary board %x", b)
+	}
+}
+
+func TestCreateEchoesBoard(t *testing.T) {
+	mux := testMux()
+	// defaults are echoed as applied, and match the stored game
+	obj := decode(t, request(mux, "POST", "/games/", url.Values{}))
+	state := decode(t, request(mux, "GET", "/games/"+obj["uuid"].(string), nil))
+	for _, key := range []string{"width", "height", "mines"} {
+		if nil == obj[key] || state[key] != obj[key] {
+			t.Fatalf("created %s %v, but the game has %v", key, obj[key], state[key])
+		}
+	}
+	if "custom" != obj["difficulty"] || true != obj["question_marks"] || true != obj["auto_chord"] || "square" != obj["topology"] {
+		t.Fatalf("created %v", obj)
+	}
+	if _, ok := obj["seed"]; ok {
+		t.Fatal("unseeded game echoed a seed")
+	}
+	obj = decode(t, request(mux, "POST", "/games/", url.Values{"difficulty": {"expert"}, "chord": {"0"}}))
+	if 30.0 != obj["width"] || 16.0 != obj["height"] || 99.0 != obj["mines"] || "expert" != obj["difficulty"] || false != obj["auto_chord"] {
+		t.Fatalf("created expert %v", obj)
+	}
+	obj = decode(t, request(mux, "POST", "/games/", url.Values{"w": {"10"}, "h": {"10"}, "density": {"0.25"}}))
+	if 25.0 != obj["mines"] || 0.25 != obj["density"] {
+		t.Fatalf("created by density %v", obj)
+	}
+	obj = decode(t, request(mux, "POST", "/games/", url.Values{"w": {"9"}, "h": {"7"}, "m": {"10"}, "seed": {"3"}, "zero": {"1"}}))
+	if opening, _ := obj["opening"].([]interface{}); 3.0 != obj["seed"] || true != obj["zero"] || 2 != len(opening) || 4.0 != opening[0] || 3.0 != opening[1] {
+		t.Fatalf("created seeded %v", obj)
+	}
+}
+
+func TestCreateWithoutMines(t *testing.T) {
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{"w": {"5"}, "h": {"5"}, "m": {"0"}})
+	if obj := decode(t, request(mux, "POST", "/games/"+uid, url.Values{"x": {"0"}, "y": {"0"}})); true != obj["won"] {
+		t.Fatalf("empty board after a click %v", obj)
+	}
+}
+
+func TestDeepHealthz(t *testing.T) {
+	mux := testMux()
+	w := request(mux, "GET", "/healthz?deep=1", nil)
+	obj := decode(t, w)
+	if _, ok := obj["generate_us"].(float64); http.StatusOK != w.Code || "ok" != obj["status"] || !ok {
+		t.Fatalf("deep healthz got %d: %v", w.Code, obj)
+	}
+	if _, ok := decode(t, request(mux, "GET", "/healthz", nil))["generate_us"]; ok {
+		t.Fatal("shallow healthz generated a board")
+	}
+	// generation that can't finish in time fails the check
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := checkGeneration(ctx, time.Second); nil == err {
+		t.Fatal("cancelled generation passed the check")
+	}
+}
+
+func TestContinueEndpoint(t *testing.T) {
+	mux := testMux()
+	board := url.Values{"w": {"9"}, "h": {"9"}, "m": {"1"}, "seed": {"8"}, "zero": {"1"}}
+	whole := createGame(t, mux, board)
+	want := decode(t, request(mux, "POST", "/games/"+whole, url.Values{"x": {"4"}, "y": {"4"}}))
+	board.Set("cascade", "5")
+	uid := createGame(t, mux, board)
+	obj := decode(t, request(mux, "POST", "/games/"+uid, url.Values{"x": {"4"}, "y": {"4"}}))
+	if nil == obj["frontier"] {
+		t.Fatalf("click opened past the cascade limit: %v", obj)
+	}
+	for nil != obj["frontier"] {
+		w := request(mux, "POST", "/games/"+uid+"/continue", url.Values{})
+		if http.StatusAccepted != w.Code {
+			t.Fatalf("continue got %d: %s", w.Code, w.Body.String())
+		}
+		obj = decode(t, w)
+	}
+	if !reflect.DeepEqual(want["tiles"], obj["tiles"]) || 1.0 != obj["clicks"] {
+		t.Fatalf("continued to %v, want %v", obj, want)
+	}
+	if w := request(mux, "POST", "/games/"+uid+"/continue", url.Values{}); http.StatusBadRequest != w.Code {
+		t.Fatalf("continue with nothing left got %d", w.Code)
+	}
+}
+
+func TestMinIntervalEndpoint(t *testing.T) {
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}, "min_interval": {"60000"}})
+	if w := request(mux, "POST", "/games/"+uid, url.Values{"x": {"0"}, "y": {"0"}, "flag": {"1"}}); http.StatusAccepted != w.Code {
+		t.Fatalf("first click got %d", w.Code)
+	}
+	if w := request(mux, "POST", "/games/"+uid, url.Values{"x": {"1"}, "y": {"0"}, "flag": {"1"}}); http.StatusTooManyRequests != w.Code {
+		t.Fatalf("fast click got %d", w.Code)
+	}
+}
+
+func TestCreateEchoesOptions(t *testing.T) {
+	mux := testMux()
+	w := request(mux, "POST", "/games/", url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}, "cascade": {"5"}, "min_interval": {"250"}, "nocluster": {"1"}})
+	obj := decode(t, w)
+	if 5.0 != obj["cascade"] || 250.0 != obj["min_interval"] || true != obj["nocluster"] {
+		t.Fatalf("created %v", obj)
+	}
+	obj = decode(t, request(mux, "POST", "/games/", url.Values{}))
+	if 0.0 != obj["cascade"] || 0.0 != obj["min_interval"] || false != obj["nocluster"] {
+		t.Fatalf("created %v", obj)
+	}
+}
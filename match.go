@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jeffchannell/mines-server/mines"
+)
+
+// match races two players on identical boards
+type match struct {
+	id    uuid.UUID    // match uuid
+	seed  int64        // seed both boards were placed from
+	games [2]uuid.UUID // the racing games
+}
+
+var (
+	matches   map[uuid.UUID]*match
+	matchesMu sync.RWMutex
+)
+
+func init() {
+	matches = make(map[uuid.UUID]*match)
+}
+
+// newMatch of two games on the board asked for, sharing a random seed
+func newMatch(b boardParams) (mt *match, err error) {
+	uid, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+	mt = &match{id: uid}
+	// zero means unseeded, so never share it
+	for 0 == mt.seed {
+		mt.seed = rand.Int63()
+	}
+	options := b.options
+	options.Seed = mt.seed
+	var created [2]*mines.Game
+	for i := range created {
+		created[i], err = mines.NewGameWithOptions(b.width, b.height, b.mines, options)
+		if err != nil {
+			return nil, err
+		}
+		mt.games[i] = created[i].UUID()
+	}
+	for i, g := range created {
+		if err := storeGame(g); err != nil {
+			// don't leave half a match behind
+			if 1 == i {
+				deleteGame(created[0].UUID())
+			}
+			return nil, err
+		}
+	}
+	matchesMu.Lock()
+	matches[uid] = mt
+	matchesMu.Unlock()
+	return mt, nil
+}
+
+// getMatchByUUIDString from memory
+func getMatchByUUIDString(uuidStr string) (mt *match, err error) {
+	uid, err := uuid.Parse(uuidStr)
+	if err != nil {
+		return nil, err
+	}
+	matchesMu.RLock()
+	defer matchesMu.RUnlock()
+	if mt, ok := matches[uid]; ok {
+		return mt, nil
+	}
+	return nil, errors.New("invalid Match")
+}
+
+// standing of a racing game, read under its lock
+type standing struct {
+	uid      uuid.UUID
+	status   string
+	endedAt  time.Time
+	revealed int
+}
+
+// standingOf a stored game, or nil once it is deleted
+func standingOf(uid uuid.UUID) *standing {
+	g, err := lockGame(uid.String())
+	if err != nil {
+		return nil
+	}
+	defer g.Unlock()
+	return &standing{uid: uid, status: g.Status(), endedAt: g.EndedAt(), revealed: g.Revealed()}
+}
+
+// winner of the match: the first to win, or the furthest to get when both
+// lose. Zero while undecided.
+func (mt *match) winner() (uid uuid.UUID) {
+	a := standingOf(mt.games[0])
+	b := standingOf(mt.games[1])
+	// a deleted game forfeits the match
+	if nil == a || nil == b {
+		if nil != a {
+			return a.uid
+		} else if nil != b {
+			return b.uid
+		}
+		return uid
+	}
+	switch {
+	case "won" == a.status && "won" == b.status:
+		if b.endedAt.Before(a.endedAt) {
+			return b.uid
+		}
+		return a.uid
+	case "won" == a.status:
+		return a.uid
+	case "won" == b.status:
+		return b.uid
+	case "lost" == a.status && "lost" == b.status:
+		if a.revealed < b.revealed {
+			return b.uid
+		}
+		return a.uid
+	}
+	return uid
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jeffchannell/mines-server/mines"
+)
+
+// newTestMatch on a beginner board, failing the test if it can't be made
+func newTestMatch(t *testing.T) *match {
+	t.Helper()
+	mt, err := newMatch(boardParams{width: 9, height: 9, mines: 10, options: mines.DefaultOptions()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mt
+}
+
+// lose a stored game by clicking its first mine
+func lose(t *testing.T, uid uuid.UUID) {
+	t.Helper()
+	g, err := lockGame(uid.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Unlock()
+	for i, v := range g.Solution() {
+		if 9 == v {
+			g.ClickTile(uint16(i%9), uint16(i/9), false)
+			return
+		}
+	}
+}
+
+func TestMatchSharesBoard(t *testing.T) {
+	mt := newTestMatch(t)
+	a, _ := getGameByUUIDString(mt.games[0].String())
+	b, _ := getGameByUUIDString(mt.games[1].String())
+	if mt.seed != a.Seed() || !bytes.Equal(a.Solution(), b.Solution()) {
+		t.Fatal("match games are on different boards")
+	}
+	if uuid.Nil != mt.winner() {
+		t.Fatal("undecided match has a winner")
+	}
+}
+
+func TestMatchWinner(t *testing.T) {
+	mt := newTestMatch(t)
+	g, _ := getGameByUUIDString(mt.games[1].String())
+	g.Lock()
+	win(t, g)
+	g.Unlock()
+	if mt.games[1] != mt.winner() {
+		t.Fatal("first to win didn't win the match")
+	}
+	// both losing goes to whoever got further
+	mt = newTestMatch(t)
+	g, _ = getGameByUUIDString(mt.games[0].String())
+	g.Lock()
+	g.ClickTile(4, 4, false)
+	g.Unlock()
+	lose(t, mt.games[0])
+	lose(t, mt.games[1])
+	if mt.games[0] != mt.winner() {
+		t.Fatal("furthest loser didn't win the match")
+	}
+	// deleting a game forfeits the match
+	mt = newTestMatch(t)
+	deleteGame(mt.games[0])
+	if mt.games[1] != mt.winner() {
+		t.Fatal("deleted game didn't forfeit")
+	}
+}
+
+func TestMatchEndpoint(t *testing.T) {
+	mux := testMux()
+	w := request(mux, "POST", "/matches/", url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}})
+	if http.StatusCreated != w.Code {
+		t.Fatalf("match got %d: %s", w.Code, w.Body.String())
+	}
+	obj := decode(t, w)
+	id, _ := obj["id"].(string)
+	games, _ := obj["games"].([]interface{})
+	// the seed would give the board away
+	if _, ok := decode(t, request(mux, "GET", "/matches/"+id, nil))["seed"]; ok {
+		t.Fatal("undecided match shares its seed")
+	}
+	request(mux, "POST", "/games/"+games[0].(string)+"/forfeit", url.Values{})
+	request(mux, "POST", "/games/"+games[1].(string)+"/forfeit", url.Values{})
+	obj = decode(t, request(mux, "GET", "/matches/"+id, nil))
+	if nil == obj["winner"] || nil == obj["seed"] {
+		t.Fatalf("decided match is %v", obj)
+	}
+	if w = request(mux, "GET", "/matches/"+uuid.NewString(), nil); http.StatusNotFound != w.Code {
+		t.Fatalf("unknown match got %d", w.Code)
+	}
+}
+
+func TestMatchBoardOptions(t *testing.T) {
+	mux := testMux()
+	w := request(mux, "POST", "/matches/", url.Values{"w": {"10"}, "h": {"10"}, "density": {"0.2"}, "wrap": {"1"}})
+	if http.StatusCreated != w.Code {
+		t.Fatalf("match got %d: %s", w.Code, w.Body.String())
+	}
+	games, _ := decode(t, w)["games"].([]interface{})
+	for _, uid := range games {
+		obj := decode(t, request(mux, "GET", "/games/"+uid.(string), nil))
+		if float64(mines.MinesForDensity(10, 10, 0.2)) != obj["mines"] || true != obj["wrap"] {
+			t.Fatalf("match game ignored density or wrap: %v", obj)
+		}
+	}
+	if _, ok := invalidFields(t, request(mux, "POST", "/matches/", url.Values{"w": {"x"}}))["w"]; !ok {
+		t.Fatal("bad width not reported")
+	}
+	// a match picks its own seed
+	if _, ok := invalidFields(t, request(mux, "POST", "/matches/", url.Values{"seed": {"3"}}))["seed"]; !ok {
+		t.Fatal("match seed not reported")
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"github.com/jeffchannell/mines-server/mines"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	gamesCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "mines_games_created_total",
+		Help: "Games created.",
+	})
+	gamesEnded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mines_games_ended_total",
+		Help: "Games ended, by outcome.",
+	}, []string{"outcome"})
+	gameDurations = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "mines_game_duration_seconds",
+		Help:    "Time from creation to the end of a game.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+	})
+	activeGamesDesc = prometheus.NewDesc(
+		"mines_games_active",
+		"Games in memory that have not ended.",
+		nil, nil,
+	)
+)
+
+// gamesCollector reports the games currently in memory at scrape time
+type gamesCollector struct{}
+
+// Describe the collected metrics
+func (gamesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- activeGamesDesc
+}
+
+// Collect the number of active games
+func (gamesCollector) Collect(ch chan<- prometheus.Metric) {
+	var active int
+	eachGame(func(g *mines.Game) {
+		if "active" == g.Status() {
+			active++
+		}
+	})
+	ch <- prometheus.MustNewConstMetric(activeGamesDesc, prometheus.GaugeValue, float64(active))
+}
+
+func init() {
+	prometheus.MustRegister(gamesCreated, gamesEnded, gameDurations, gamesCollector{})
+	mines.OnEnd(func(g *mines.Game) {
+		gamesEnded.WithLabelValues(g.Status()).Inc()
+		gameDurations.Observe(g.Duration().Seconds())
+	})
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// metric value scraped from /metrics, by its name and labels as printed
+func metric(t *testing.T, h http.Handler, name string) float64 {
+	t.Helper()
+	w := request(h, "GET", "/metrics", nil)
+	if http.StatusOK != w.Code {
+		t.Fatalf("metrics got %d", w.Code)
+	}
+	s := bufio.NewScanner(w.Body)
+	for s.Scan() {
+		if v, ok := strings.CutPrefix(s.Text(), name+" "); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return f
+		}
+	}
+	return 0
+}
+
+func TestMetrics(t *testing.T) {
+	mux := testMux()
+	created := metric(t, mux, "mines_games_created_total")
+	lost := metric(t, mux, `mines_games_ended_total{outcome="lost"}`)
+	durations := metric(t, mux, "mines_game_duration_seconds_count")
+	uid := createGame(t, mux, url.Values{})
+	if created+1 != metric(t, mux, "mines_games_created_total") {
+		t.Fatal("created game not counted")
+	}
+	if 1 > metric(t, mux, "mines_games_active") {
+		t.Fatal("created game not active")
+	}
+	request(mux, "POST", "/games/"+uid+"/forfeit", url.Values{})
+	if lost+1 != metric(t, mux, `mines_games_ended_total{outcome="lost"}`) {
+		t.Fatal("forfeit not counted as a loss")
+	}
+	if durations+1 != metric(t, mux, "mines_game_duration_seconds_count") {
+		t.Fatal("forfeit duration not observed")
+	}
+}
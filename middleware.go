@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// gzipMinSize is the smallest body worth compressing, in bytes
+const gzipMinSize = 1024
+
+// gzipWriter buffers the start of a response so small bodies can skip
+// compression, then streams the rest through gzip
+type gzipWriter struct {
+	http.ResponseWriter
+	status int
+	buf    bytes.Buffer
+	zw     *gzip.Writer
+}
+
+// WriteHeader holds the status until the body is known
+func (g *gzipWriter) WriteHeader(code int) {
+	g.status = code
+}
+
+// Write buffers the body until it is worth compressing
+func (g *gzipWriter) Write(b []byte) (int, error) {
+	if nil != g.zw {
+		return g.zw.Write(b)
+	}
+	g.buf.Write(b)
+	if gzipMinSize <= g.buf.Len() {
+		g.Header().Set("Content-Encoding", "gzip")
+		g.Header().Del("Content-Length")
+		g.ResponseWriter.WriteHeader(g.status)
+		g.zw = gzip.NewWriter(g.ResponseWriter)
+		if _, err := g.zw.Write(g.buf.Bytes()); err != nil {
+			return 0, err
+		}
+		g.buf.Reset()
+	}
+	return len(b), nil
+}
+
+// withGzip compresses responses for clients that accept gzip
+func withGzip(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			next(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Accept-Encoding")
+		gw := &gzipWriter{ResponseWriter: w, status: http.StatusOK}
+		next(gw, r)
+		if nil != gw.zw {
+			gw.zw.Close()
+			return
+		}
+		// send small bodies as they are
+		w.WriteHeader(gw.status)
+		if 0 < gw.buf.Len() {
+			w.Write(gw.buf.Bytes())
+		}
+	}
+}
+
+// requestLog writes one line per request, as text or JSON
+var requestLog = log.New(os.Stderr, "", log.LstdFlags)
+
+// logJSON switches the request log to JSON lines
+var logJSON = "json" == os.Getenv("MINES_SERVER_LOG_FORMAT")
+
+func init() {
+	if logJSON {
+		requestLog.SetFlags(0)
+	}
+}
+
+// statusWriter records the status code sent to the client
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status
+func (s *statusWriter) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// withLogging logs the method, path, status, duration and game of a request
+func withLogging(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next(sw, r)
+		duration := time.Since(start)
+		// the game is the first path segment, or the header
+		var game string
+		p := strings.Split(strings.TrimPrefix(r.URL.Path, "/games/"), "/")
+		if uid, err := uuid.Parse(p[0]); err == nil {
+			game = uid.String()
+		} else if uid, err := uuid.Parse(r.Header.Get("X-GAME-UUID")); err == nil {
+			game = uid.String()
+		}
+		if !logJSON {
+			requestLog.Printf("%s %s %d %s %s", r.Method, r.URL.Path, sw.status, duration, game)
+			return
+		}
+		line, err := json.Marshal(map[string]interface{}{
+			"time":        start,
+			"method":      r.Method,
+			"path":        r.URL.Path,
+			"status":      sw.status,
+			"duration_ms": float64(duration) / float64(time.Millisecond),
+			"game":        game,
+		})
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		requestLog.Print(string(line))
+	}
+}
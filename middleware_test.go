@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// gzipped response to a request for target through h
+func gzipped(h http.Handler, target string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", target, nil)
+	r.Header.Set("Accept-Encoding", "gzip, deflate")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestGzipLargeBodies(t *testing.T) {
+	body := strings.Repeat("?", 2*gzipMinSize)
+	h := withGzip(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		// written in pieces, so the start is buffered before compressing
+		for i := 0; i < len(body); i += 100 {
+			end := i + 100
+			if len(body) < end {
+				end = len(body)
+			}
+			io.WriteString(w, body[i:end])
+		}
+	})
+	w := gzipped(h, "/")
+	if http.StatusAccepted != w.Code || "gzip" != w.Header().Get("Content-Encoding") {
+		t.Fatalf("got %d with encoding %q", w.Code, w.Header().Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := io.ReadAll(zr); body != string(got) {
+		t.Fatalf("decompressed %d bytes, want %d", len(got), len(body))
+	}
+	// clients that can't decompress get the body as it is
+	r := httptest.NewRequest("GET", "/", nil)
+	plain := httptest.NewRecorder()
+	h(plain, r)
+	if "" != plain.Header().Get("Content-Encoding") || body != plain.Body.String() {
+		t.Fatal("compressed for a client that didn't accept gzip")
+	}
+}
+
+func TestGzipSkipsSmallBodies(t *testing.T) {
+	h := withGzip(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"error":"nope"}`)
+	})
+	w := gzipped(h, "/")
+	if http.StatusNotFound != w.Code || "" != w.Header().Get("Content-Encoding") || `{"error":"nope"}` != w.Body.String() {
+		t.Fatalf("small body sent as %d %q %q", w.Code, w.Header().Get("Content-Encoding"), w.Body.String())
+	}
+}
+
+func TestGzipGameState(t *testing.T) {
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{"w": {"30"}, "h": {"16"}, "m": {"99"}})
+	w := gzipped(mux, "/games/"+uid)
+	if http.StatusOK != w.Code || "gzip" != w.Header().Get("Content-Encoding") {
+		t.Fatalf("expert board sent as %d %q", w.Code, w.Header().Get("Content-Encoding"))
+	}
+}
+
+// logged lines from requests to h while logging as JSON or not
+func logged(t *testing.T, h http.HandlerFunc, asJSON bool, r *http.Request) string {
+	t.Helper()
+	var buf bytes.Buffer
+	out, flags, was := requestLog.Writer(), requestLog.Flags(), logJSON
+	requestLog.SetOutput(&buf)
+	requestLog.SetFlags(0)
+	logJSON = asJSON
+	defer func() {
+		requestLog.SetOutput(out)
+		requestLog.SetFlags(flags)
+		logJSON = was
+	}()
+	withLogging(h)(httptest.NewRecorder(), r)
+	return buf.String()
+}
+
+func TestRequestLogging(t *testing.T) {
+	uid := "0d3a6c42-5e1f-11ee-8c99-0242ac120002"
+	h := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}
+	line := logged(t, h, false, httptest.NewRequest("POST", "/games/"+uid+"/auto", nil))
+	if !strings.HasPrefix(line, "POST /games/"+uid+"/auto 418 ") || !strings.HasSuffix(line, " "+uid+"\n") {
+		t.Fatalf("logged %q", line)
+	}
+	// the game can be named by header instead
+	r := httptest.NewRequest("GET", "/games/", nil)
+	r.Header.Set("X-GAME-UUID", uid)
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(logged(t, h, true, r)), &obj); err != nil {
+		t.Fatal(err)
+	}
+	if "GET" != obj["method"] || "/games/" != obj["path"] || 418.0 != obj["status"] || uid != obj["game"] {
+		t.Fatalf("logged %v", obj)
+	}
+	if _, ok := obj["duration_ms"].(float64); !ok {
+		t.Fatalf("logged %v", obj)
+	}
+}
+
+func TestCORSOrigins(t *testing.T) {
+	was := corsOrigins
+	defer func() { corsOrigins = was }()
+	origin := func(from string) http.Header {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Origin", from)
+		w := httptest.NewRecorder()
+		setCORSOrigin(w, r)
+		return w.Header()
+	}
+	corsOrigins = nil
+	if h := origin("https://a.example"); "*" != h.Get("Access-Control-Allow-Origin") {
+		t.Fatalf("unconfigured origins allow %q", h.Get("Access-Control-Allow-Origin"))
+	}
+	corsOrigins = []string{"https://a.example", "https://b.example"}
+	if h := origin("https://b.example"); "https://b.example" != h.Get("Access-Control-Allow-Origin") || "Origin" != h.Get("Vary") {
+		t.Fatalf("allowed origin got %v", h)
+	}
+	if h := origin("https://c.example"); "" != h.Get("Access-Control-Allow-Origin") {
+		t.Fatalf("other origin allowed as %q", h.Get("Access-Control-Allow-Origin"))
+	}
+}
@@ -0,0 +1,70 @@
+package mines
+
+import (
+	"encoding/binary"
+)
+
+// Tile codes in the binary encoding, one nibble per tile
+const (
+	BinaryMine      = 9  // unflagged mine on a lost board
+	BinaryWrongFlag = 10 // flag on a safe tile on a lost board
+	BinaryFlag      = 11 // flag placed by the player
+	BinaryQuestion  = 12 // uncertain tile
+	BinaryHidden    = 13 // unchecked tile
+	BinaryAutoMine  = 14 // unflagged mine on a won board
+)
+
+// EncodeBinary packs the public state of the board for clients short on
+// bandwidth. Numbers are big endian:
+//
+//	0-1  width
+//	2-3  height
+//	4-5  flags placed
+//	6    status: 0 active, 1 won, 2 lost
+//	7-   a nibble per tile in row order, high nibble first: 0-8 for
+//	     open tiles by neighboring mines, otherwise one of the Binary codes
+func (g *Game) EncodeBinary() []byte {
+	n := int(g.width) * int(g.height)
+	b := make([]byte, 7+(n+1)/2)
+	binary.BigEndian.PutUint16(b[0:], g.width)
+	binary.BigEndian.PutUint16(b[2:], g.height)
+	binary.BigEndian.PutUint16(b[4:], g.flags)
+	won, lost := g.won, !g.won && !g.endedAt.IsZero()
+	if won {
+		b[6] = 1
+	} else if lost {
+		b[6] = 2
+	}
+	for i := 0; i < n; i++ {
+		// before the board is generated, every tile is hidden
+		var code byte = BinaryHidden
+		if nil != g.tiles {
+			code = nibble(g.tiles[i], won, lost)
+		}
+		if 0 == i%2 {
+			code <<= 4
+		}
+		b[7+i/2] |= code
+	}
+	return b
+}
+
+// nibble for a tile in a game that may be won or lost, in the same order of
+// precedence as symbol
+func nibble(t tile, won, lost bool) byte {
+	isMine := 9 == t.value
+	if lost && isMine && !t.flagged {
+		return BinaryMine
+	} else if lost && !isMine && t.flagged {
+		return BinaryWrongFlag
+	} else if t.flagged {
+		return BinaryFlag
+	} else if won && isMine {
+		return BinaryAutoMine
+	} else if t.question {
+		return BinaryQuestion
+	} else if !t.clicked {
+		return BinaryHidden
+	}
+	return t.value
+}
@@ -0,0 +1,114 @@
+package mines
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+)
+
+// decodeBinary tiles from EncodeBinary output, as the symbols JSON would
+// show them
+func decodeBinary(t *testing.T, b []byte) (w, h, flags uint16, status byte, tiles []string) {
+	t.Helper()
+	if 7 > len(b) {
+		t.Fatalf("encoding is %d bytes, too short for a header", len(b))
+	}
+	w = binary.BigEndian.Uint16(b[0:])
+	h = binary.BigEndian.Uint16(b[2:])
+	flags = binary.BigEndian.Uint16(b[4:])
+	status = b[6]
+	n := int(w) * int(h)
+	if 7+(n+1)/2 != len(b) {
+		t.Fatalf("encoding is %d bytes, want %d", len(b), 7+(n+1)/2)
+	}
+	symbols := map[byte]string{
+		BinaryMine:      DefaultSymbols.Mine,
+		BinaryWrongFlag: DefaultSymbols.WrongFlag,
+		BinaryFlag:      DefaultSymbols.Flag,
+		BinaryQuestion:  DefaultSymbols.Question,
+		BinaryHidden:    DefaultSymbols.Hidden,
+		BinaryAutoMine:  DefaultSymbols.AutoMine,
+	}
+	for i := 0; i < n; i++ {
+		c := b[7+i/2]
+		if 0 == i%2 {
+			c >>= 4
+		}
+		c &= 0xf
+		s, ok := symbols[c]
+		if !ok {
+			s = DefaultSymbols.Numbers[c]
+		}
+		tiles = append(tiles, s)
+	}
+	return w, h, flags, status, tiles
+}
+
+// checkBinary decodes the game's binary state the same as its JSON
+func checkBinary(t *testing.T, g *Game, status byte) {
+	t.Helper()
+	w, h, flags, got, tiles := decodeBinary(t, g.EncodeBinary())
+	if g.width != w || g.height != h || g.flags != flags || status != got {
+		t.Fatalf("header %dx%d, %d flags, status %d", w, h, flags, got)
+	}
+	js, _ := g.JSON()
+	var state struct{ Tiles []string }
+	if err := json.Unmarshal([]byte(js), &state); err != nil {
+		t.Fatal(err)
+	}
+	for i := range tiles {
+		if state.Tiles[i] != tiles[i] {
+			t.Fatalf("tile %d decodes as %q, JSON has %q", i, tiles[i], state.Tiles[i])
+		}
+	}
+}
+
+func TestEncodeBinaryMatchesJSON(t *testing.T) {
+	g, err := NewGameWithOptions(9, 7, 10, Options{Seed: 5, QuestionMarks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(status byte) {
+		t.Helper()
+		checkBinary(t, g, status)
+	}
+	check(0)
+	g.ClickTile(4, 3, false)
+	var mine, safe int = -1, -1
+	for idx := range g.tiles {
+		if g.tiles[idx].clicked {
+			continue
+		}
+		if 9 == g.tiles[idx].value && -1 == mine {
+			mine = idx
+		} else if 9 != g.tiles[idx].value && -1 == safe {
+			safe = idx
+		}
+	}
+	// a wrong flag, and a question mark, before losing
+	g.ClickTile(uint16(safe%9), uint16(safe/9), true)
+	g.ClickTile(uint16(mine%9), uint16(mine/9), true)
+	g.ClickTile(uint16(mine%9), uint16(mine/9), true)
+	check(0)
+	g.ClickTile(uint16(mine%9), uint16(mine/9), true)
+	g.ClickTile(uint16(mine%9), uint16(mine/9), false)
+	check(2)
+}
+
+func TestEncodeBinaryWon(t *testing.T) {
+	g, err := NewGameFromLayout(3, 3, [][2]uint16{{0, 0}, {2, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// one mine flagged, the other left for the win to mark
+	g.ClickTile(0, 0, true)
+	g.ClickTile(1, 2, false)
+	g.ClickTile(1, 0, false)
+	if "won" != g.Status() {
+		t.Fatalf("game is %s, want won", g.Status())
+	}
+	checkBinary(t, g, 1)
+	if _, _, _, _, tiles := decodeBinary(t, g.EncodeBinary()); DefaultSymbols.AutoMine != tiles[2] {
+		t.Fatalf("unflagged mine on a win decodes as %q", tiles[2])
+	}
+}
@@ -0,0 +1,18 @@
+package mines
+
+import (
+	"time"
+)
+
+// Clock tells a game the current time
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock reads the system time
+type realClock struct{}
+
+// Now returns the current local time
+func (realClock) Now() time.Time {
+	return time.Now()
+}
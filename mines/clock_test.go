@@ -0,0 +1,141 @@
+package mines
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// manualClock only moves when a test moves it
+type manualClock struct{ t time.Time }
+
+func (c *manualClock) Now() time.Time { return c.t }
+
+// clockedGame on a board with a single corner mine, timed by a manual clock
+func clockedGame(t *testing.T) (*Game, *manualClock) {
+	t.Helper()
+	clk := &manualClock{t: time.Unix(5000, 0)}
+	g, err := NewGameWithOptions(5, 5, 1, Options{Clock: clk, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g, clk
+}
+
+func TestPauseFreezesClock(t *testing.T) {
+	g, clk := clockedGame(t)
+	clk.t = clk.t.Add(10 * time.Second)
+	if err := g.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Pause(); nil == err {
+		t.Fatal("paused twice")
+	}
+	clk.t = clk.t.Add(time.Hour)
+	if 10*time.Second != g.Duration() || true != stateOf(t, g, View{})["paused"] {
+		t.Fatalf("paused game lasted %v", g.Duration())
+	}
+	if err := g.ClickTile(2, 2, false); nil == err {
+		t.Fatal("paused game took a click")
+	}
+	if err := g.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Resume(); nil == err {
+		t.Fatal("resumed a running game")
+	}
+	clk.t = clk.t.Add(5 * time.Second)
+	if 15*time.Second != g.Duration() {
+		t.Fatalf("resumed game lasted %v, want 15s", g.Duration())
+	}
+	// ending while paused stops the clock where it was paused
+	g.Pause()
+	clk.t = clk.t.Add(time.Minute)
+	g.End(false)
+	clk.t = clk.t.Add(time.Minute)
+	if 15*time.Second != g.Duration() || g.Paused() {
+		t.Fatalf("ended game lasted %v, paused %v", g.Duration(), g.Paused())
+	}
+	if err := g.Pause(); nil == err {
+		t.Fatal("paused an ended game")
+	}
+}
+
+func TestInjectedClock(t *testing.T) {
+	g, clk := clockedGame(t)
+	start := clk.t
+	clk.t = clk.t.Add(3 * time.Second)
+	for i, v := range g.Solution() {
+		if 9 != v {
+			g.ClickTile(uint16(i%5), uint16(i/5), false)
+		}
+	}
+	if "won" != g.Status() {
+		t.Fatalf("game is %s, want won", g.Status())
+	}
+	clk.t = clk.t.Add(time.Hour)
+	if !g.startedAt.Equal(start) || !g.EndedAt().Equal(start.Add(3*time.Second)) || 3*time.Second != g.Duration() {
+		t.Fatalf("started %v, ended %v, lasted %v", g.startedAt, g.EndedAt(), g.Duration())
+	}
+	if !g.history[0].takenAt.Equal(start.Add(3 * time.Second)) {
+		t.Fatalf("turn taken at %v", g.history[0].takenAt)
+	}
+	obj := stateOf(t, g, View{Epoch: true})
+	if 5000000.0 != obj["started_at"] || 5003000.0 != obj["ended_at"] {
+		t.Fatalf("state times are %v and %v", obj["started_at"], obj["ended_at"])
+	}
+}
+
+func TestEpochTimes(t *testing.T) {
+	g, clk := clockedGame(t)
+	clk.t = clk.t.Add(1234567 * time.Microsecond)
+	g.End(false)
+	rfc, epoch := stateOf(t, g, View{}), stateOf(t, g, View{Epoch: true})
+	for _, key := range []string{"started_at", "ended_at"} {
+		at, err := time.Parse(time.RFC3339Nano, rfc[key].(string))
+		if err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+		ms, ok := epoch[key].(float64)
+		if !ok || at.UnixMilli() != int64(ms) {
+			t.Fatalf("%s is %v but %v in epoch ms", key, rfc[key], epoch[key])
+		}
+	}
+	if 5001234.0 != epoch["ended_at"] {
+		t.Fatalf("ended at %v", epoch["ended_at"])
+	}
+}
+
+func TestTurnTimes(t *testing.T) {
+	g, clk := clockedGame(t)
+	if _, ok := stateOf(t, g, View{})["turn_times"]; ok {
+		t.Fatal("turn times shown without asking")
+	}
+	for _, c := range [][2]uint16{{0, 0}, {4, 0}, {0, 4}} {
+		clk.t = clk.t.Add(250 * time.Millisecond)
+		if err := g.ClickTile(c[0], c[1], true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	times, _ := stateOf(t, g, View{Timings: true})["turn_times"].([]interface{})
+	if len(g.history) != len(times) {
+		t.Fatalf("%d turn times for %d turns", len(times), len(g.history))
+	}
+	for i, ms := range times {
+		if want := float64(5000000 + 250*(i+1)); want != ms {
+			t.Fatalf("turn %d taken at %v, want %v", i, ms, want)
+		}
+	}
+	// an earlier turn only knows the times up to it
+	js, err := g.TurnView("1", View{Timings: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var obj map[string]interface{}
+	if err = json.Unmarshal([]byte(js), &obj); err != nil {
+		t.Fatal(err)
+	}
+	if times, _ = obj["turn_times"].([]interface{}); 2 != len(times) {
+		t.Fatalf("turn 1 has times %v", times)
+	}
+}
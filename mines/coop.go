@@ -0,0 +1,75 @@
+package mines
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// member of a shared game
+type member struct {
+	token string // secret identifying the player's clicks
+	name  string // name shown in replays
+}
+
+// Join a shared game, returning the token the player clicks with
+func (g *Game) Join(name string) (playerToken string, err error) {
+	if !g.endedAt.IsZero() {
+		return "", errors.New("Game is not active")
+	}
+	name = cleanName(name)
+	if "" == name {
+		return "", errors.New("player name required")
+	}
+	uid, err := uuid.NewRandom()
+	if err != nil {
+		return "", err
+	}
+	g.members = append(g.members, member{token: uid.String(), name: name})
+	return uid.String(), nil
+}
+
+// ClickTileAs activates a tile on behalf of a joined player
+func (g *Game) ClickTileAs(playerToken string, x, y uint16, flag bool) (err error) {
+	n := -1
+	for i := range g.members {
+		if playerToken == g.members[i].token {
+			n = i
+			break
+		}
+	}
+	if -1 == n {
+		return errors.New("unknown player")
+	}
+	if g.options.TakeTurns && n != g.next {
+		return errors.New("not your turn")
+	}
+	if err = g.paced(); err != nil {
+		return err
+	}
+	before := len(g.history)
+	err = g.click(context.Background(), x, y, flag)
+	if err != nil {
+		return err
+	}
+	// attribute the turn, and pass play on, only if something changed
+	if before < len(g.history) {
+		g.history[before].player = playerToken
+		g.next = (n + 1) % len(g.members)
+	}
+	return nil
+}
+
+// member holding a token, or nil
+func (g *Game) member(token string) *member {
+	if "" == token {
+		return nil
+	}
+	for i := range g.members {
+		if token == g.members[i].token {
+			return &g.members[i]
+		}
+	}
+	return nil
+}
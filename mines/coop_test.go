@@ -0,0 +1,82 @@
+package mines
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+)
+
+func TestSharedGameAttributesTurns(t *testing.T) {
+	g, err := NewGameFromLayout(7, 7, [][2]uint16{{0, 0}, {6, 6}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ann, err := g.Join("ann")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := g.Join("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = g.Join(" \n"); nil == err {
+		t.Fatal("joined without a name")
+	}
+	if err = g.ClickTileAs(ann, 0, 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if err = g.ClickTileAs(bob, 6, 6, true); err != nil {
+		t.Fatal(err)
+	}
+	// turns taken without a token belong to nobody
+	if err = g.ClickTile(3, 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if err = g.ClickTileAs("nobody", 1, 1, false); nil == err {
+		t.Fatal("unknown player clicked")
+	}
+	for i, want := range []interface{}{"ann", "bob", nil} {
+		js, err := g.Turn(strconv.Itoa(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var obj map[string]interface{}
+		json.Unmarshal([]byte(js), &obj)
+		if want != obj["turn_player"] {
+			t.Errorf("turn %d played by %v, want %v", i, obj["turn_player"], want)
+		}
+	}
+}
+
+func TestSharedGameTakesTurns(t *testing.T) {
+	o := DefaultOptions()
+	o.TakeTurns = true
+	g, err := NewGameWithOptions(7, 7, 2, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ann, _ := g.Join("ann")
+	bob, _ := g.Join("bob")
+	if err = g.ClickTile(0, 0, true); nil == err {
+		t.Fatal("clicked without a token while taking turns")
+	}
+	if err = g.ClickTileAs(bob, 0, 0, true); nil == err {
+		t.Fatal("bob played first")
+	}
+	if err = g.ClickTileAs(ann, 0, 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if err = g.ClickTileAs(ann, 1, 0, true); nil == err {
+		t.Fatal("ann played twice in a row")
+	}
+	// a click that changes nothing, like one on a flag, doesn't use up a turn
+	if err = g.ClickTileAs(bob, 0, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	if err = g.ClickTileAs(bob, 1, 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if err = g.ClickTileAs(ann, 2, 0, true); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,67 @@
+package mines
+
+import (
+	"encoding/json"
+)
+
+// TileChange is a tile whose symbol changed during a turn
+type TileChange struct {
+	X   uint16 `json:"x"`
+	Y   uint16 `json:"y"`
+	Val string `json:"val"`
+}
+
+// TurnDelta lists the tiles changed by the latest turn
+func (g *Game) TurnDelta() []TileChange {
+	return g.TurnDeltaView(View{})
+}
+
+// TurnDeltaView lists the tiles changed by the latest turn, with symbols as
+// viewed
+func (g *Game) TurnDeltaView(v View) []TileChange {
+	changes := []TileChange{}
+	if 0 == len(g.history) {
+		return changes
+	}
+	// tiles as they were before the latest turn, when the game was active
+	before := make(map[int]tile)
+	for _, c := range g.history[len(g.history)-1].changes {
+		if _, ok := before[c.index]; !ok {
+			before[c.index] = c.before
+		}
+	}
+	w := int(g.width)
+	for i := 0; i < len(g.tiles); i++ {
+		was, ok := before[i]
+		if !ok {
+			was = g.tiles[i]
+		}
+		if now := g.symbolView(g.tiles[i], v); v.symbols().symbol(was, false, false) != now {
+			changes = append(changes, TileChange{
+				X:   uint16(i % w),
+				Y:   uint16(i / w),
+				Val: now,
+			})
+		}
+	}
+	return changes
+}
+
+// DeltaJSON writes the board state to a JSON string, with only the tiles
+// changed by the latest turn
+func (g *Game) DeltaJSON() (string, error) {
+	return g.DeltaJSONView(View{})
+}
+
+// DeltaJSONView writes the board state to a JSON string, as viewed, with
+// only the tiles changed by the latest turn
+func (g *Game) DeltaJSONView(v View) (string, error) {
+	obj := g.state(len(g.history)-1, v)
+	delete(obj, "tiles")
+	obj["changes"] = g.TurnDeltaView(v)
+	json, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	return string(json), nil
+}
@@ -0,0 +1,70 @@
+package mines
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTurnDelta(t *testing.T) {
+	g, err := NewGameFromLayout(3, 2, [][2]uint16{{0, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := g.TurnDelta(); 0 != len(d) {
+		t.Fatalf("delta before any turn is %v", d)
+	}
+	g.ClickTile(2, 0, false)
+	want := map[[2]uint16]string{{1, 0}: "1", {2, 0}: "", {1, 1}: "1", {2, 1}: ""}
+	d := g.TurnDelta()
+	if len(want) != len(d) {
+		t.Fatalf("delta is %v, want %v", d, want)
+	}
+	for _, c := range d {
+		if v, ok := want[[2]uint16{c.X, c.Y}]; !ok || v != c.Val {
+			t.Fatalf("delta is %v, want %v", d, want)
+		}
+	}
+	g.ClickTile(0, 1, true)
+	if d = g.TurnDelta(); 1 != len(d) || (TileChange{X: 0, Y: 1, Val: "!"}) != d[0] {
+		t.Fatalf("flag delta is %v", d)
+	}
+}
+
+func TestTurnDeltaOnLoss(t *testing.T) {
+	g, err := NewGameFromLayout(3, 3, [][2]uint16{{0, 0}, {0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.ClickTile(2, 0, false)
+	g.ClickTile(0, 0, false)
+	// the mine that went off, and the one left hidden
+	d := g.TurnDelta()
+	if 2 != len(d) {
+		t.Fatalf("loss delta is %v", d)
+	}
+	for _, c := range d {
+		if 0 != c.X || "9" != c.Val {
+			t.Fatalf("loss delta is %v", d)
+		}
+	}
+}
+
+func TestDeltaJSON(t *testing.T) {
+	g, err := NewGameFromLayout(3, 2, [][2]uint16{{0, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.ClickTile(2, 0, false)
+	js, err := g.DeltaJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var obj map[string]interface{}
+	json.Unmarshal([]byte(js), &obj)
+	if _, ok := obj["tiles"]; ok {
+		t.Fatal("delta has the whole grid")
+	}
+	if changes, _ := obj["changes"].([]interface{}); 4 != len(changes) || 1.0 != obj["clicks"] {
+		t.Fatalf("delta state is %s", js)
+	}
+}
@@ -0,0 +1,85 @@
+package mines
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+)
+
+// Export encodes the board definition, without any revealed state, as a code
+// that ImportGame can rebuild. The layout is the width and height as 16-bit
+// big-endian values, then one bit per tile set for mines, as URL-safe base64.
+func (g *Game) Export() (string, error) {
+	if !g.placed {
+		return "", errors.New("board is not generated yet")
+	}
+	b := make([]byte, 4+(len(g.tiles)+7)/8)
+	binary.BigEndian.PutUint16(b[0:], g.width)
+	binary.BigEndian.PutUint16(b[2:], g.height)
+	for i := 0; i < len(g.tiles); i++ {
+		if 9 == g.tiles[i].value {
+			b[4+i/8] |= 1 << uint(i%8)
+		}
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// ImportGame starts a new game from an exported board code
+func ImportGame(code string) (*Game, error) {
+	b, err := base64.RawURLEncoding.DecodeString(code)
+	if err != nil {
+		return nil, err
+	}
+	if 4 > len(b) {
+		return nil, errors.New("invalid board code")
+	}
+	w := binary.BigEndian.Uint16(b[0:])
+	h := binary.BigEndian.Uint16(b[2:])
+	if len(b) != 4+(int(w)*int(h)+7)/8 {
+		return nil, errors.New("invalid board code")
+	}
+	var mines [][2]uint16
+	for i := 0; i < int(w)*int(h); i++ {
+		if 0 != b[4+i/8]&(1<<uint(i%8)) {
+			mines = append(mines, [2]uint16{uint16(i % int(w)), uint16(i / int(w))})
+		}
+	}
+	return NewGameFromLayout(w, h, mines)
+}
+
+// Solution is the value of every tile, 0-8 or 9 for mines
+func (g *Game) Solution() []uint8 {
+	if !g.placed {
+		return nil
+	}
+	values := make([]uint8, len(g.tiles))
+	for i := 0; i < len(g.tiles); i++ {
+		values[i] = g.tiles[i].value
+	}
+	return values
+}
+
+// Restart starts a new game on the same board with the same options, once
+// this one has ended
+func (g *Game) Restart() (*Game, error) {
+	if g.endedAt.IsZero() {
+		return nil, errors.New("Game is still active")
+	}
+	r, err := NewGameWithOptions(g.width, g.height, g.mines, g.options)
+	if err != nil {
+		return nil, err
+	}
+	// a seeded board is laid out again by the constructor, others are copied
+	if !r.placed && g.placed {
+		r.tiles = make([]tile, len(g.tiles))
+		for i := range g.tiles {
+			r.tiles[i].value = g.tiles[i].value
+		}
+		r.placed = true
+		r.seed = 0
+	}
+	r.player = g.player
+	// the board has been seen before
+	r.ranked = false
+	return r, nil
+}
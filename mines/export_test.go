@@ -0,0 +1,103 @@
+package mines
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	for _, o := range []Options{{Seed: 4}, {Seed: 9, Wrap: true}} {
+		g, err := NewGameWithOptions(11, 6, 12, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, err := g.Export()
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := ImportGame(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g.width != r.width || g.height != r.height || g.mines != r.mines {
+			t.Fatalf("imported %dx%d with %d mines", r.width, r.height, r.mines)
+		}
+		for i := range g.tiles {
+			if (9 == g.tiles[i].value) != (9 == r.tiles[i].value) {
+				t.Fatalf("mine %d moved on import", i)
+			}
+		}
+	}
+}
+
+func TestExportBeforeLayout(t *testing.T) {
+	g, _ := NewGame(9, 9, 10)
+	if _, err := g.Export(); nil == err {
+		t.Fatal("exported a board with no mines laid out")
+	}
+}
+
+func TestImportGameRejectsBadCodes(t *testing.T) {
+	for _, code := range []string{"", "!!", "AAk", "AAkACQ"} {
+		if _, err := ImportGame(code); nil == err {
+			t.Fatalf("imported %q", code)
+		}
+	}
+}
+
+func TestSolution(t *testing.T) {
+	g, _ := NewGame(9, 9, 10)
+	if nil != g.Solution() {
+		t.Fatal("solution before the mines are laid out")
+	}
+	g, err := NewGameFromLayout(2, 2, [][2]uint16{{1, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint8{1, 9, 1, 1}
+	got := g.Solution()
+	for i := range want {
+		if want[i] != got[i] {
+			t.Fatalf("solution is %v, want %v", got, want)
+		}
+	}
+	// changing the copy leaves the board alone
+	got[0] = 9
+	if 1 != g.tiles[0].value {
+		t.Fatal("solution shares the board")
+	}
+}
+
+func TestRestart(t *testing.T) {
+	g, err := NewGame(9, 9, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.SetPlayerName("ann")
+	if _, err = g.Restart(); nil == err {
+		t.Fatal("restarted an active game")
+	}
+	g.ClickTile(4, 4, false)
+	g.End(false)
+	r, err := g.Restart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(g.Solution(), r.Solution()) || g.UUID() == r.UUID() || "ann" != r.PlayerName() {
+		t.Fatal("restart isn't the same board under a new game")
+	}
+	// the board has been seen, so the restart can't rank or be replayed
+	if "active" != r.Status() || 0 != r.Clicks() || r.Ranked() || 0 != r.Seed() {
+		t.Fatalf("restart is %s with %d clicks, ranked %v, seed %d", r.Status(), r.Clicks(), r.Ranked(), r.Seed())
+	}
+	// a seeded board is dealt again from its seed
+	s, _ := NewGameWithOptions(9, 9, 10, Options{Seed: 8})
+	s.End(false)
+	r, err = s.Restart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(s.Solution(), r.Solution()) || 8 != r.Seed() {
+		t.Fatal("seeded restart isn't dealt from the seed")
+	}
+}
@@ -1,20 +1,29 @@
 package mines
 
 import (
+	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+	"unicode"
 
 	"github.com/google/uuid"
 )
 
 // tile in the game
 type tile struct {
-	value   uint8
-	flagged bool
-	clicked bool
+	value    uint8
+	flagged  bool
+	question bool
+	clicked  bool
 }
 
 // turn taken in the game
@@ -24,11 +33,19 @@ type turn struct {
 	y       uint16    // click y coordinate
 	flag    bool      // tile flagging was enabled
 	takenAt time.Time // time turn was taken
-	tiles   []tile    // game tiles
+	changes []change  // tiles changed by the turn
+	player  string    // token of the player who took the turn, if joined
+	resumed bool      // turn continued a reveal cut short, so isn't a click
+}
+
+// change to a tile, holding the tile as it was before the turn
+type change struct {
+	index  int
+	before tile
 }
 
 // newTurn for the game
-func newTurn(x uint16, y uint16, f bool) (t *turn, err error) {
+func newTurn(x uint16, y uint16, f bool, takenAt time.Time) (t *turn, err error) {
 	uid, err := uuid.NewUUID()
 	if err != nil {
 		return nil, err
@@ -38,30 +55,181 @@ func newTurn(x uint16, y uint16, f bool) (t *turn, err error) {
 		x:       x,
 		y:       y,
 		flag:    f,
-		takenAt: time.Now(),
+		takenAt: takenAt,
 	}
 	return t, nil
 }
 
-// Game represents a single mines game being played
+// Topology of the board, deciding which tiles are neighbors
+type Topology string
+
+// Topologies a board can have
+const (
+	Square Topology = "square" // 8 neighbors
+	Hex    Topology = "hex"    // 6 neighbors, odd rows shifted half a tile right
+)
+
+var (
+	// squareOffsets around a tile
+	squareOffsets = [][2]int{{-1, -1}, {0, -1}, {1, -1}, {-1, 0}, {1, 0}, {-1, 1}, {0, 1}, {1, 1}}
+	// hexEvenOffsets around a tile on an even row
+	hexEvenOffsets = [][2]int{{-1, -1}, {0, -1}, {-1, 0}, {1, 0}, {-1, 1}, {0, 1}}
+	// hexOddOffsets around a tile on an odd row
+	hexOddOffsets = [][2]int{{0, -1}, {1, -1}, {-1, 0}, {1, 0}, {0, 1}, {1, 1}}
+)
+
+// Options that change how a game is played
+type Options struct {
+	QuestionMarks bool          // unflagging a tile marks it uncertain
+	Secure        bool          // place mines using crypto/rand
+	Wrap          bool          // board edges wrap around to the opposite edge
+	Topology      Topology      // square when empty
+	Clock         Clock         // source of time, the system clock when nil
+	TakeTurns     bool          // joined players must click in the order they joined
+	Seed          int64         // place mines from this seed when not zero, only the center is safe
+	Protected     [][2]uint16   // tiles that never hold a mine, besides the first click
+	ZeroOpening   bool          // the first click always opens an empty region
+	AutoChord     bool          // clicking a satisfied number opens its neighbors
+	CascadeLimit  int           // tiles one turn can open before stopping, unlimited when zero
+	MinInterval   time.Duration // shortest time allowed between turns, any when zero
+	NoClusters    bool          // avoid laying out tiles that border 7 or 8 mines
+}
+
+// DefaultOptions for a new game
+func DefaultOptions() Options {
+	return Options{
+		QuestionMarks: true,
+		AutoChord:     true,
+	}
+}
+
+// clusterRetries is how many times a mine is drawn again to keep clusters
+// apart, before it is placed wherever it lands
+const clusterRetries = 100
+
+// ErrTooFast is returned for a click that comes sooner after the last turn
+// than the game allows
+var ErrTooFast = errors.New("click came too soon after the last turn")
+
+// Limits on new boards, which a server may change before creating games
+var (
+	MaxWidth  uint16 = 250
+	MaxHeight uint16 = 250
+	MaxMines  uint16 = 65535
+)
+
+// TooManyMinesError is returned for a board that can't hold the mines asked
+// for, with the most it could
+type TooManyMinesError struct {
+	Width, Height, Mines, Max uint16
+}
+
+// NewTooManyMinesError for m mines on a w by h board
+func NewTooManyMinesError(w, h, m uint16) *TooManyMinesError {
+	return &TooManyMinesError{Width: w, Height: h, Mines: m, Max: MaxMinesFor(w, h)}
+}
+
+func (e *TooManyMinesError) Error() string {
+	return fmt.Sprintf("mines %d exceed maximum %d for %dx%d", e.Mines, e.Max, e.Width, e.Height)
+}
+
+// MinesForDensity on a w by h board, rounded and clamped to what a new game
+// can hold
+func MinesForDensity(w, h uint16, density float64) uint16 {
+	m := math.Round(density * float64(w) * float64(h))
+	if limit := float64(MaxMinesFor(w, h)); limit < m {
+		m = limit
+	}
+	if 0 > m {
+		m = 0
+	}
+	return uint16(m)
+}
+
+// MaxMinesFor a w by h board, leaving room for the first click and its
+// neighbor
+func MaxMinesFor(w, h uint16) uint16 {
+	max := int(w)*int(h) - 2
+	if int(MaxMines) < max {
+		max = int(MaxMines)
+	}
+	if 0 > max {
+		max = 0
+	}
+	return uint16(max)
+}
+
+// maxPlayerName length, in characters
+const maxPlayerName = 32
+
+// Difficulty presets, as width, height and mines
+var Difficulties = map[string][3]uint16{
+	"beginner":     {9, 9, 10},
+	"intermediate": {16, 16, 40},
+	"expert":       {30, 16, 99},
+}
+
+// Game represents a single mines game being played. Its methods don't lock,
+// so callers sharing a game between goroutines must hold its Lock.
 type Game struct {
-	uid       uuid.UUID    // game uuid
-	width     uint16       // width, in tiles
-	height    uint16       // height, in tiles
-	mines     uint16       // number of mines that should be on the board
-	flags     uint16       // how many flags are set
-	startedAt time.Time    // time game started
-	endedAt   time.Time    // time game ended
-	won       bool         // game was won
-	history   map[int]turn // game history
-}
-
-// NewGame starts a new game
+	mu        sync.Mutex    // held by callers around every use
+	uid       uuid.UUID     // game uuid
+	options   Options       // game options
+	rng       Generator     // mine placement source
+	seed      int64         // seed the board is dealt from, zero if it isn't
+	player    string        // player name
+	width     uint16        // width, in tiles
+	height    uint16        // height, in tiles
+	mines     uint16        // number of mines that should be on the board
+	flags     uint16        // how many flags are set
+	startedAt time.Time     // time game started
+	endedAt   time.Time     // time game ended
+	pausedAt  time.Time     // time game was paused, zero while running
+	paused    time.Duration // time spent paused before pausedAt
+	won       bool          // game was won
+	detonated *[2]uint16    // mine that lost the game
+	tiles     []tile        // current tiles, nil until the first click
+	placed    bool          // mines are laid out, which waits for a reveal
+	frontier  [][2]uint16   // tiles left to reveal past the cascade limit
+	history   map[int]*turn // game history
+	members   []member      // players who joined a shared game
+	next      int           // member whose turn it is, when taking turns
+	ranked    bool          // the server dealt the board unseen, so it can rank
+	// last turn of the game this one was imported from, for MinInterval
+	importedTurnAt time.Time
+}
+
+// Lock the game for the calling goroutine
+func (g *Game) Lock() {
+	g.mu.Lock()
+}
+
+// TryLock the game without waiting, reporting if it was locked
+func (g *Game) TryLock() bool {
+	return g.mu.TryLock()
+}
+
+// Unlock the game
+func (g *Game) Unlock() {
+	g.mu.Unlock()
+}
+
+// NewGame starts a new game with the default options
 func NewGame(w, h, m uint16) (g *Game, err error) {
-	var maxW, maxH, maxM int
-	maxW = 250
-	maxH = 250
-	maxM = int(w)*int(h) - 2
+	return NewGameWithOptions(w, h, m, DefaultOptions())
+}
+
+// NewGameWithOptions starts a new game
+func NewGameWithOptions(w, h, m uint16, o Options) (g *Game, err error) {
+	return NewGameWithContext(context.Background(), w, h, m, o)
+}
+
+// NewGameWithContext starts a new game, giving up on laying out a seeded
+// board if ctx is done first
+func NewGameWithContext(ctx context.Context, w, h, m uint16, o Options) (g *Game, err error) {
+	var maxW, maxH int
+	maxW = int(MaxWidth)
+	maxH = int(MaxHeight)
 	uid, err := uuid.NewUUID()
 	if err != nil {
 		return nil, err
@@ -72,23 +240,171 @@ func NewGame(w, h, m uint16) (g *Game, err error) {
 	if maxH < int(h) {
 		return nil, errors.New("height exceeds max")
 	}
-	if maxM < int(m) {
-		return nil, errors.New("mines exceed tiles")
+	if 0 == w || 0 == h {
+		return nil, errors.New("board has no tiles")
+	}
+	// a board without mines is won on any first click, however small
+	if 0 < m && MaxMinesFor(w, h) < m {
+		return nil, NewTooManyMinesError(w, h, m)
+	}
+	// narrower boards would count a tile as its own neighbor
+	if o.Wrap && (3 > w || 3 > h) {
+		return nil, errors.New("wrapped boards must be at least 3x3")
+	}
+	switch o.Topology {
+	case "":
+		o.Topology = Square
+	case Square:
+	case Hex:
+		// rows must keep alternating across the wrapped edge
+		if o.Wrap && 1 == h%2 {
+			return nil, errors.New("wrapped hex boards need an even height")
+		}
+	default:
+		return nil, errors.New("unknown topology")
+	}
+	// a seeded board has to be reproducible
+	if o.Secure && 0 != o.Seed {
+		return nil, errors.New("secure boards cannot be seeded")
+	}
+	// protected tiles, plus the first click, must leave room for the mines
+	protected := make(map[[2]uint16]bool)
+	for _, c := range o.Protected {
+		if w <= c[0] || h <= c[1] {
+			return nil, fmt.Errorf("protected tile %d,%d is off the board", c[0], c[1])
+		}
+		protected[c] = true
+	}
+	if int(w)*int(h)-len(protected)-1 < int(m) {
+		return nil, errors.New("protected tiles leave too few tiles for the mines")
+	}
+	// an opening needs the first click's whole neighborhood clear, wherever
+	// it lands
+	if o.ZeroOpening && 0 < m {
+		opening := 9
+		if Hex == o.Topology {
+			opening = 7
+		}
+		if int(w)*int(h)-len(protected)-opening < int(m) {
+			return nil, errors.New("too many mines for a zero opening")
+		}
 	}
 	g = &Game{
-		uid:       uid,
-		height:    h,
-		width:     w,
-		mines:     m,
-		startedAt: time.Now(),
+		uid:     uid,
+		options: o,
+		height:  h,
+		width:   w,
+		mines:   m,
+		// a caller's seed gives the board away before it is played
+		ranked: 0 == o.Seed,
+	}
+	if nil == o.Clock {
+		g.options.Clock = realClock{}
+	}
+	// keep our own copy, so the caller can't move protected tiles later
+	g.options.Protected = append([][2]uint16(nil), o.Protected...)
+	g.startedAt = g.now()
+	g.history = make(map[int]*turn)
+	if o.Secure {
+		g.rng = cryptoGenerator{}
+	} else {
+		// every other game gets a seed of its own now, so its board only
+		// depends on the seed and the first click. The shared source it is
+		// drawn from is seeded randomly by the runtime, so each process deals
+		// different boards; a caller's seed is used as is.
+		g.seed = o.Seed
+		for 0 == g.seed {
+			g.seed = rand.Int63()
+		}
+		g.rng = rand.New(rand.NewSource(g.seed))
+	}
+	// boards seeded by the caller are laid out now, around a safe center, so
+	// every game from the same seed is identical whatever the first click
+	if 0 != o.Seed {
+		g.tiles, err = g.generateTiles(ctx, w/2, h/2)
+		if err != nil {
+			return nil, err
+		}
+		g.placed = true
 	}
-	g.history = make(map[int]turn)
+
+	return g, nil
+}
+
+// NewGameFromLayout starts a new game with mines at the given coordinates,
+// instead of placing them on the first click
+func NewGameFromLayout(w, h uint16, mines [][2]uint16) (g *Game, err error) {
+	if int(^uint16(0)) < len(mines) {
+		return nil, errors.New("mines exceed tiles")
+	}
+	g, err = NewGame(w, h, uint16(len(mines)))
+	if err != nil {
+		return nil, err
+	}
+	tiles := make([]tile, int(g.height)*int(g.width))
+	for _, c := range mines {
+		if g.width <= c[0] || g.height <= c[1] {
+			return nil, fmt.Errorf("mine %d,%d is off the board", c[0], c[1])
+		}
+		if 9 == tiles[g.index(c[0], c[1])].value {
+			return nil, fmt.Errorf("mine %d,%d is placed twice", c[0], c[1])
+		}
+		tiles[g.index(c[0], c[1])].value = 9
+	}
+	g.countMines(tiles)
+	g.tiles = tiles
+	g.placed = true
+	g.ranked = false
+	// the board wasn't dealt from the seed, so it can't replay it
+	g.seed = 0
 
 	return g, nil
 }
 
 // ClickTile activates a tile
 func (g *Game) ClickTile(x, y uint16, flag bool) (err error) {
+	return g.ClickTileContext(context.Background(), x, y, flag)
+}
+
+// ClickTileContext activates a tile, giving up on generating the board if
+// ctx is done first
+func (g *Game) ClickTileContext(ctx context.Context, x, y uint16, flag bool) (err error) {
+	// shared games taking turns need to know who is clicking
+	if g.options.TakeTurns && 0 < len(g.members) {
+		return errors.New("player token required")
+	}
+	if err = g.paced(); err != nil {
+		return err
+	}
+	return g.click(ctx, x, y, flag)
+}
+
+// paced refuses an action that comes sooner after the last turn than the
+// game allows, as bots click faster than people can. It is checked once per
+// action, so the clicks an action makes aren't held apart.
+func (g *Game) paced() error {
+	last := g.lastTurnAt()
+	if 0 < g.options.MinInterval && !last.IsZero() && g.now().Sub(last) < g.options.MinInterval {
+		return ErrTooFast
+	}
+	return nil
+}
+
+// lastTurnAt is when the latest turn was taken, zero before the first
+func (g *Game) lastTurnAt() time.Time {
+	if 0 < len(g.history) {
+		return g.history[len(g.history)-1].takenAt
+	}
+	return g.importedTurnAt
+}
+
+// Validate a click at x,y without making it
+func (g *Game) Validate(x, y uint16) error {
+	return g.canClick(x, y)
+}
+
+// canClick reports why a click at x,y would be refused, if it would
+func (g *Game) canClick(x, y uint16) error {
 	// validate x
 	if g.width <= x {
 		return errors.New("X cannot be larger than the board width")
@@ -97,161 +413,787 @@ func (g *Game) ClickTile(x, y uint16, flag bool) (err error) {
 	if g.height <= y {
 		return errors.New("Y cannot be larger than the board height")
 	}
+	// bail if game was lost
+	if !g.endedAt.IsZero() {
+		return errors.New("Game is not active")
+	}
+	// no clicking while the clock is stopped
+	if g.Paused() {
+		return errors.New("Game is paused")
+	}
+	return nil
+}
+
+// click a tile, recording the turn
+func (g *Game) click(ctx context.Context, x, y uint16, flag bool) (err error) {
+	if err = g.canClick(x, y); err != nil {
+		return err
+	}
 	// generate turn object
-	turn, err := newTurn(x, y, flag)
+	turn, err := newTurn(x, y, flag, g.now())
 	if err != nil {
 		return err
 	}
-	// add tiles to turn and add turn to history stack
-	var tiles []tile
-	if 0 == len(g.history) { // add first turn
-		tiles = g.generateTiles(x, y)
-	} else { // add subsequent turn
-		tiles = make([]tile, g.height*g.width)
-		copy(tiles, g.history[len(g.history)-1].tiles)
+	idx := g.index(x, y)
+	// lay out the mines on the first reveal, so flagging first doesn't fix
+	// which tile is safe
+	if !g.placed && !flag && !(nil != g.tiles && g.tiles[idx].flagged) {
+		tiles, err := g.generateTiles(ctx, x, y)
+		if err != nil {
+			return err
+		}
+		// keep anything marked before then, and let replays of those turns
+		// see the board as it was
+		for i := range g.tiles {
+			tiles[i].flagged = g.tiles[i].flagged
+			tiles[i].question = g.tiles[i].question
+			if tiles[i] != g.tiles[i] {
+				turn.changes = append(turn.changes, change{index: i, before: g.tiles[i]})
+			}
+		}
+		g.tiles = tiles
+		g.placed = true
+	} else if nil == g.tiles {
+		// marks made before then go on a blank board
+		g.tiles = make([]tile, int(g.height)*int(g.width))
 	}
-	turn.tiles = tiles
-	g.history[len(g.history)] = *turn
+	// add turn to history stack
+	g.history[len(g.history)] = turn
 
-	// bail if game was lost
-	if !g.endedAt.IsZero() {
-		return errors.New("Game is not active")
-	}
 	// get tile
-	tile := &turn.tiles[g.width*y+x]
+	tile := &g.tiles[idx]
 
 	if tile.clicked { // tile is already clicked
-		if !flag { // not toggling flags, click neighbors
+		if !flag && g.options.AutoChord { // not toggling flags, click neighbors
 			if f := g.countFlags(x, y); f == tile.value {
 				g.clickNeighbors(x, y)
 			}
 		}
 	} else if !tile.flagged { // tile is not flagged
-		if flag { // toggle flag
-			tile.flagged = true
+		if flag && tile.question { // clear the question mark
+			g.edit(idx).question = false
+		} else if flag { // toggle flag
+			g.edit(idx).flagged = true
 			g.flags++
 		} else { // click tile
-			tile.clicked = true
-			if 9 == tile.value { // tile is a mine - game over!
-				g.endedAt = time.Now()
-			} else if 0 == tile.value { // tile has 0 neighboring mines - open neighbors too
-				g.clickNeighbors(x, y)
-			}
+			g.revealTile(x, y)
 		}
 	} else if tile.flagged && flag { // tile is flagged and we are turning off the flag
+		tile = g.edit(idx)
 		tile.flagged = false
 		g.flags--
+		// cycle to a question mark, if enabled
+		tile.question = g.options.QuestionMarks
 	}
-	// check win condition
-	if g.endedAt.IsZero() {
-		var total int
-		for i := 0; i < len(turn.tiles); i++ {
-			if turn.tiles[i].clicked || (9 == turn.tiles[i].value) {
-				total++
+	// drop turns that changed nothing, so replays stay meaningful
+	if 0 == len(turn.changes) {
+		delete(g.history, len(g.history)-1)
+		return nil
+	}
+	// check win condition, never on a board that was just lost
+	if g.endedAt.IsZero() && g.cleared() {
+		g.End(true)
+	}
+	return
+}
+
+// ContinueReveal opens more of a reveal cut short by the cascade limit, as a
+// turn of its own that doesn't count as a click
+func (g *Game) ContinueReveal() error {
+	if 0 == len(g.frontier) {
+		return errors.New("nothing left to reveal")
+	}
+	c := g.frontier[len(g.frontier)-1]
+	if err := g.canClick(c[0], c[1]); err != nil {
+		return err
+	}
+	if err := g.paced(); err != nil {
+		return err
+	}
+	turn, err := newTurn(c[0], c[1], false, g.now())
+	if err != nil {
+		return err
+	}
+	turn.resumed = true
+	g.history[len(g.history)] = turn
+	stack := g.frontier
+	g.frontier = nil
+	g.reveal(stack)
+	// the player may have opened the rest already
+	if 0 == len(turn.changes) {
+		delete(g.history, len(g.history)-1)
+	}
+	if g.endedAt.IsZero() && g.cleared() {
+		g.End(true)
+	}
+	return nil
+}
+
+// Frontier of tiles a reveal cut short by the cascade limit has yet to open
+func (g *Game) Frontier() [][2]uint16 {
+	return append([][2]uint16(nil), g.frontier...)
+}
+
+// revealTile on the current turn, spreading across empty tiles
+func (g *Game) revealTile(x, y uint16) {
+	g.reveal([][2]uint16{{x, y}})
+}
+
+// reveal tiles on the current turn, flooding out from empty tiles with an
+// explicit stack so large openings don't grow the call stack. The numbered
+// border of an opening is revealed but not flooded past, and flagged tiles
+// are left closed. Past the cascade limit, the tiles still to go are left on
+// the frontier for ContinueReveal.
+func (g *Game) reveal(stack [][2]uint16) {
+	var opened int
+	for 0 < len(stack) {
+		if 0 < g.options.CascadeLimit && g.options.CascadeLimit <= opened {
+			for _, c := range stack {
+				if t := g.tiles[g.index(c[0], c[1])]; !t.clicked && !t.flagged {
+					g.frontier = append(g.frontier, c)
+				}
 			}
+			return
+		}
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		idx := g.index(c[0], c[1])
+		// skip tiles opened since they were queued
+		if g.tiles[idx].clicked || g.tiles[idx].flagged {
+			continue
 		}
-		if total == len(turn.tiles) {
-			g.End(true)
+		tile := g.edit(idx)
+		tile.clicked = true
+		tile.question = false
+		opened++
+		if 9 == tile.value { // tile is a mine - game over!
+			g.detonated = &c
+			g.End(false)
+			return
+		} else if 0 == tile.value { // tile has 0 neighboring mines - open neighbors too
+			stack = append(stack, g.closedNeighbors(c[0], c[1])...)
 		}
 	}
-	return
 }
 
-// End the game
+// index of a tile in the grid
+func (g *Game) index(x, y uint16) int {
+	return int(g.width)*int(y) + int(x)
+}
+
+// offsets to the neighbors of a tile on row y
+func (g *Game) offsets(y int) [][2]int {
+	if Hex != g.options.Topology {
+		return squareOffsets
+	} else if 0 == y%2 {
+		return hexEvenOffsets
+	}
+	return hexOddOffsets
+}
+
+// neighbors of a tile, wrapping at the edges if enabled
+func (g *Game) neighbors(x, y uint16) (n [][2]uint16) {
+	var h, w int
+	h = int(g.height)
+	w = int(g.width)
+	for _, o := range g.offsets(int(y)) {
+		// get new x,y coords
+		x2 := int(x) + o[0]
+		y2 := int(y) + o[1]
+		if g.options.Wrap {
+			x2 = (x2 + w) % w
+			y2 = (y2 + h) % h
+		} else if 0 > x2 || 0 > y2 || x2 >= w || y2 >= h {
+			// skip out of bounds coords
+			continue
+		}
+		n = append(n, [2]uint16{uint16(x2), uint16(y2)})
+	}
+	return n
+}
+
+// cleared reports if every safe tile has been clicked; flags don't count
+func (g *Game) cleared() bool {
+	if nil == g.tiles {
+		return false
+	}
+	for i := 0; i < len(g.tiles); i++ {
+		if 9 != g.tiles[i].value && !g.tiles[i].clicked {
+			return false
+		}
+	}
+	return true
+}
+
+// edit a tile on the current turn, recording how it was before
+func (g *Game) edit(idx int) *tile {
+	t := g.history[len(g.history)-1]
+	t.changes = append(t.changes, change{index: idx, before: g.tiles[idx]})
+	return &g.tiles[idx]
+}
+
+// tilesAt reconstructs the tiles as they were after turn i, by undoing the
+// changes of every later turn
+func (g *Game) tilesAt(i int) []tile {
+	if nil == g.tiles {
+		return nil
+	}
+	tiles := make([]tile, len(g.tiles))
+	copy(tiles, g.tiles)
+	for n := len(g.history) - 1; n > i; n-- {
+		changes := g.history[n].changes
+		for c := len(changes) - 1; c >= 0; c-- {
+			tiles[changes[c].index] = changes[c].before
+		}
+	}
+	return tiles
+}
+
+// Clicks taken to reveal tiles, not counting flag toggles
+func (g *Game) Clicks() int {
+	return g.clicksTo(len(g.history) - 1)
+}
+
+// clicksTo turn i, counted as Clicks does
+func (g *Game) clicksTo(i int) (total int) {
+	for n := 0; n <= i; n++ {
+		if !g.history[n].flag && !g.history[n].resumed {
+			total++
+		}
+	}
+	return total
+}
+
+// endHooks run whenever a game ends
+var endHooks []func(*Game)
+
+// OnEnd registers a function to run whenever any game ends
+func OnEnd(f func(*Game)) {
+	endHooks = append(endHooks, f)
+}
+
+// End the game, unless it has already ended. A win is only recorded once
+// every safe tile is clicked.
 func (g *Game) End(won bool) {
-	g.endedAt = time.Now()
-	g.won = true
+	if !g.endedAt.IsZero() || (won && !g.cleared()) {
+		return
+	}
+	g.endedAt = g.now()
+	g.frontier = nil
+	// a game ended while paused stops the clock where it was paused
+	if g.Paused() {
+		g.paused += g.endedAt.Sub(g.pausedAt)
+		g.pausedAt = time.Time{}
+	}
+	g.won = won
+	for _, f := range endHooks {
+		f(g)
+	}
+}
+
+// now according to the game clock
+func (g *Game) now() time.Time {
+	return g.options.Clock.Now()
+}
+
+// Pause stops the game clock until Resume is called
+func (g *Game) Pause() error {
+	if !g.endedAt.IsZero() {
+		return errors.New("Game is not active")
+	}
+	if g.Paused() {
+		return errors.New("Game is already paused")
+	}
+	g.pausedAt = g.now()
+	return nil
+}
+
+// Resume restarts the game clock after a Pause
+func (g *Game) Resume() error {
+	if !g.Paused() {
+		return errors.New("Game is not paused")
+	}
+	g.paused += g.now().Sub(g.pausedAt)
+	g.pausedAt = time.Time{}
+	return nil
+}
+
+// Paused reports if the game clock is stopped
+func (g *Game) Paused() bool {
+	return !g.pausedAt.IsZero()
+}
+
+// SetPlayerName, stripped of control characters and truncated
+func (g *Game) SetPlayerName(name string) {
+	g.player = cleanName(name)
+}
+
+// cleanName strips control characters from a player name and truncates it
+func cleanName(name string) string {
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name))
+	if r := []rune(name); maxPlayerName < len(r) {
+		name = string(r[:maxPlayerName])
+	}
+	return name
+}
+
+// PlayerName of the game
+func (g *Game) PlayerName() string {
+	return g.player
+}
+
+// Ranked reports if the board was dealt by the server, unseen by the player
+// beforehand, so a win on it can be ranked. Imported, restarted, seeded and
+// resumed games aren't.
+func (g *Game) Ranked() bool {
+	return g.ranked
+}
+
+// Won reports if the game ended in a win
+func (g *Game) Won() bool {
+	return g.won
+}
+
+// Status of the game: active, won or lost
+func (g *Game) Status() string {
+	if g.endedAt.IsZero() {
+		return "active"
+	} else if g.won {
+		return "won"
+	}
+	return "lost"
+}
+
+// ETag identifying the current state of the game, hashed from everything the
+// state shows so a rename changes it as surely as a turn. It is empty if the
+// state can't be marshaled.
+func (g *Game) ETag() string {
+	b, err := json.Marshal(g.state(len(g.history)-1, View{}))
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return fmt.Sprintf(`"%x"`, sum[:16])
+}
+
+// Duration of the game, up to now if it has not ended, less any time paused
+func (g *Game) Duration() time.Duration {
+	if g.Paused() {
+		return g.pausedAt.Sub(g.startedAt) - g.paused
+	} else if g.endedAt.IsZero() {
+		return g.now().Sub(g.startedAt) - g.paused
+	}
+	return g.endedAt.Sub(g.startedAt) - g.paused
+}
+
+// Difficulty preset matching the board, or "custom"
+func (g *Game) Difficulty() string {
+	for name, d := range Difficulties {
+		if d[0] == g.width && d[1] == g.height && d[2] == g.mines {
+			return name
+		}
+	}
+	return "custom"
 }
 
 // JSON writes the board state to a JSON string
 func (g *Game) JSON() (string, error) {
-	turn := g.history[len(g.history)-1]
-	return g.convertTurnToString(turn)
+	return g.JSONView(View{})
+}
+
+// View of a board state, adding optional fields to the JSON
+type View struct {
+	Flags   bool       // list the flagged coordinates
+	Symbols *SymbolSet // symbols for the tiles, DefaultSymbols when nil
+	Numeric bool       // tiles as cells instead of symbols
+	Epoch   bool       // times as Unix milliseconds instead of RFC 3339
+	Legacy  bool       // mines left unflagged on a win shown as flags
+	Timings bool       // list when each turn was taken
+	// a lost board shows only the mine that went off, keeping the rest of
+	// the layout hidden from a client that might yet replay the game
+	Concealed bool
+}
+
+// time as it should be marshaled for the view
+func (v View) time(t time.Time) interface{} {
+	if v.Epoch {
+		return t.UnixMilli()
+	}
+	return t
+}
+
+// symbols the view shows tiles with
+func (v View) symbols() SymbolSet {
+	symbols := DefaultSymbols
+	if nil != v.Symbols {
+		symbols = *v.Symbols
+	}
+	if v.Legacy {
+		symbols.AutoMine = symbols.Flag
+	}
+	return symbols
+}
+
+// JSONView writes the latest board state to a JSON string, as viewed
+func (g *Game) JSONView(v View) (string, error) {
+	return g.convertTurnToString(len(g.history)-1, v)
 }
 
 // Turn writes a board state from history to a JSON string
 func (g *Game) Turn(uuidStr string) (string, error) {
+	return g.TurnView(uuidStr, View{})
+}
+
+// TurnView writes a board state from history, by turn uuid or index, to a
+// JSON string, as viewed
+func (g *Game) TurnView(uuidStr string, v View) (string, error) {
+	// turns can be named by their index too
+	if n, err := strconv.Atoi(uuidStr); err == nil {
+		if 0 > n || len(g.history) <= n {
+			return "", errors.New("turn index out of range")
+		}
+		return g.convertTurnToString(n, v)
+	}
 	uid, err := uuid.Parse(uuidStr)
 	if err != nil {
-		return "", err
+		return "", errors.New("invalid turn id")
 	}
-	var t turn
 	for i := 0; i < len(g.history); i++ {
 		if uid == g.history[i].uid {
-			return g.convertTurnToString(t)
+			return g.convertTurnToString(i, v)
 		}
 	}
 	return "", errors.New("invalid turn id")
 }
 
+// Opening tile that is sure to be safe, and empty for a ZeroOpening. Seeded
+// boards are laid out before the first click, so only their center is; ok is
+// false for other boards, where whatever is clicked first is safe.
+func (g *Game) Opening() (c [2]uint16, ok bool) {
+	if 0 == g.options.Seed {
+		return c, false
+	}
+	return [2]uint16{g.width / 2, g.height / 2}, true
+}
+
+// Seed the board is placed from, or zero for secure boards and boards laid
+// out some other way, like imported or restarted ones
+func (g *Game) Seed() int64 {
+	return g.seed
+}
+
+// EndedAt is when the game ended, zero while active
+func (g *Game) EndedAt() time.Time {
+	return g.endedAt
+}
+
+// Revealed counts the safe tiles that have been clicked
+func (g *Game) Revealed() (total int) {
+	for _, t := range g.tiles {
+		if t.clicked && 9 != t.value {
+			total++
+		}
+	}
+	return total
+}
+
+// Width of the board, in tiles
+func (g *Game) Width() uint16 {
+	return g.width
+}
+
+// Height of the board, in tiles
+func (g *Game) Height() uint16 {
+	return g.height
+}
+
+// Mines on the board
+func (g *Game) Mines() uint16 {
+	return g.mines
+}
+
+// Options the game was started with
+func (g *Game) Options() Options {
+	o := g.options
+	o.Protected = append([][2]uint16(nil), g.options.Protected...)
+	return o
+}
+
 // UUID of this game
 func (g *Game) UUID() uuid.UUID {
 	return g.uid
 }
 
-func (g *Game) convertTurnToString(t turn) (string, error) {
+func (g *Game) convertTurnToString(i int, v View) (string, error) {
+	json, err := json.Marshal(g.state(i, v))
+	if err != nil {
+		return "", err
+	}
+	return string(json), nil
+}
+
+// state of the game as of turn i, ready to be marshaled
+func (g *Game) state(i int, v View) map[string]interface{} {
 	obj := make(map[string]interface{})
-	obj["started_at"] = g.startedAt
+	obj["uuid"] = g.uid
+	obj["started_at"] = v.time(g.startedAt)
 	obj["mines"] = g.mines
 	obj["height"] = g.height
 	obj["width"] = g.width
-	obj["flags"] = g.flags
-	if !g.endedAt.IsZero() {
-		obj["ended_at"] = g.endedAt
+	t := g.tilesAt(i)
+	// earlier turns only show what was known then, counted from their tiles
+	latest := len(g.history)-1 <= i
+	if latest {
+		obj["flags"] = g.flags
+	} else {
+		var flags int
+		for n := range t {
+			if t[n].flagged {
+				flags++
+			}
+		}
+		obj["flags"] = flags
+	}
+	obj["clicks"] = g.clicksTo(i)
+	obj["turns"] = i + 1
+	if g.options.Wrap {
+		obj["wrap"] = true
+	}
+	obj["topology"] = g.options.Topology
+	if "" != g.player {
+		obj["player"] = g.player
+	}
+	if latest && g.Paused() {
+		obj["paused"] = true
+	}
+	if latest && 0 < len(g.frontier) {
+		obj["frontier"] = g.frontier
+	}
+	// point a seeded board's first click at the one safe tile
+	if c, ok := g.Opening(); ok && latest && g.endedAt.IsZero() && 0 == g.Revealed() {
+		obj["opening"] = c
+	}
+	conceal := v.Concealed && !g.won
+	if latest && !g.endedAt.IsZero() && conceal {
+		obj["ended_at"] = v.time(g.endedAt)
+		if nil != g.detonated {
+			obj["detonated"] = *g.detonated
+		}
+	} else if latest && !g.endedAt.IsZero() {
+		obj["ended_at"] = v.time(g.endedAt)
+		// only report 3BV once ended, as it hints at the layout
+		bv := g.BoardValue()
+		obj["3bv"] = bv
+		if 0 != g.seed {
+			obj["seed"] = g.seed
+		}
+		// score the flags against the mines
+		var correct, wrong int
+		for idx := range g.tiles {
+			if !g.tiles[idx].flagged {
+				continue
+			}
+			if 9 == g.tiles[idx].value {
+				correct++
+			} else {
+				wrong++
+			}
+		}
+		obj["flags_correct"] = correct
+		obj["flags_wrong"] = wrong
 		if g.won {
 			obj["won"] = true
 			obj["flags"] = g.mines
+			obj["efficiency"] = 100 * float64(bv) / float64(g.Clicks())
+		} else if nil != g.detonated {
+			obj["detonated"] = *g.detonated
+			obj["mine_reveal_order"] = g.mineRevealOrder()
 		}
 	}
-	tiles := make([]string, g.height*g.width)
-	lost := !g.won && !g.endedAt.IsZero()
-	for i := 0; i < len(t.tiles); i++ {
-		var val string
-		isMine := 9 == t.tiles[i].value
-		if lost && isMine && !t.tiles[i].flagged {
-			// expose non-flagged mines if the game is over and lost
-			val = "9"
-		} else if lost && !isMine && t.tiles[i].flagged {
-			// mark incorrect flags if the game is over and lost
-			val = "X"
-		} else if t.tiles[i].flagged || (g.won && isMine) {
-			// mark flags or mines if the game was won
-			val = "!"
-		} else if !t.tiles[i].clicked {
-			// mark unchecked tiles
-			val = "?"
-		} else if 0 == t.tiles[i].value {
-			// leave empty open tiles with no label
-			val = ""
-		} else {
-			// label values 1-8
-			val = fmt.Sprintf("%d", t.tiles[i].value)
-		}
-		tiles[i] = val
-	}
-	obj["turn_id"] = t.uid
-	obj["tiles"] = tiles
-	json, err := json.Marshal(obj)
-	if err != nil {
-		return "", err
+	tiles := make([]string, int(g.height)*int(g.width))
+	// before the board is generated, every tile is hidden
+	if nil == t {
+		t = make([]tile, len(tiles))
 	}
-	return string(json), nil
+	symbols := v.symbols()
+	won, lost := g.won, !g.won && !g.endedAt.IsZero()
+	// earlier turns were played on an active board, so show them that way,
+	// as are concealed losses
+	if !latest || conceal {
+		won, lost = false, false
+	}
+	var revealed int
+	for n := 0; n < len(t); n++ {
+		tiles[n] = symbols.symbol(t[n], won, lost)
+		if t[n].clicked && 9 != t[n].value {
+			revealed++
+		}
+	}
+	if v.Numeric {
+		cells := make([]Cell, len(tiles))
+		for n := range cells {
+			cells[n] = Cell{S: "hidden"}
+			if n < len(t) {
+				cells[n] = cell(t[n], won, lost)
+			}
+		}
+		obj["tiles"] = cells
+	} else {
+		obj["tiles"] = tiles
+	}
+	// share of the safe tiles revealed, as chords reveal many per click
+	if safe := len(tiles) - int(g.mines); 0 < safe {
+		obj["progress"] = float64(revealed) / float64(safe)
+	} else {
+		obj["progress"] = 0.0
+	}
+	if v.Flags {
+		flagged := make([][2]uint16, 0)
+		for n := 0; n < len(t); n++ {
+			if t[n].flagged {
+				flagged = append(flagged, [2]uint16{uint16(n % int(g.width)), uint16(n / int(g.width))})
+			}
+		}
+		obj["flagged"] = flagged
+	}
+	// when each turn up to this one was taken, for spotting inhuman play
+	if v.Timings {
+		times := make([]int64, 0, i+1)
+		for n := 0; n <= i; n++ {
+			times = append(times, g.history[n].takenAt.UnixMilli())
+		}
+		obj["turn_times"] = times
+	}
+	var uid uuid.UUID
+	if 0 <= i {
+		uid = g.history[i].uid
+		if m := g.member(g.history[i].player); nil != m {
+			obj["turn_player"] = m.name
+		}
+	}
+	obj["turn_id"] = uid
+	return obj
 }
 
-func (g *Game) generateTiles(ignoreX, ignoreY uint16) []tile {
-	var mines uint16
-	tiles := make([]tile, g.height*g.width)
-	for mines < g.mines {
-		x := uint16(rand.Intn(int(g.width)))
-		y := uint16(rand.Intn(int(g.height)))
-		if x == ignoreX && y == ignoreY {
-			continue
+// mineRevealOrder lists every mine by distance from the detonated one, so a
+// client can stagger the explosion outward
+func (g *Game) mineRevealOrder() [][2]uint16 {
+	order := make([][2]uint16, 0, g.mines)
+	for idx := range g.tiles {
+		if 9 == g.tiles[idx].value {
+			order = append(order, [2]uint16{uint16(idx % int(g.width)), uint16(idx / int(g.width))})
 		}
-		if tiles[g.width*y+x].value != 9 {
-			tiles[g.width*y+x].value = 9
-			mines++
+	}
+	d := *g.detonated
+	dist := func(c [2]uint16) int {
+		dx := int(c[0]) - int(d[0])
+		dy := int(c[1]) - int(d[1])
+		if 0 > dx {
+			dx = -dx
 		}
+		if 0 > dy {
+			dy = -dy
+		}
+		// on a wrapped board the short way round may be the other way
+		if g.options.Wrap {
+			if w := int(g.width) - dx; w < dx {
+				dx = w
+			}
+			if h := int(g.height) - dy; h < dy {
+				dy = h
+			}
+		}
+		return dx*dx + dy*dy
 	}
+	// stable, so ties keep to row order
+	sort.SliceStable(order, func(a, b int) bool {
+		return dist(order[a]) < dist(order[b])
+	})
+	return order
+}
+
+// symbol for a tile, as shown to the player
+func (g *Game) symbol(t tile) string {
+	return g.symbolView(t, View{})
+}
+
+// symbolView for a tile, as shown to the player in the view
+func (g *Game) symbolView(t tile, v View) string {
+	return v.symbols().symbol(t, g.won, !g.won && !g.endedAt.IsZero())
+}
+
+// crowds reports if a mine at idx would leave a safe tile bordering 7 or more
+func (g *Game) crowds(tiles []tile, crowd []uint8, idx int) bool {
+	for _, c := range g.neighbors(uint16(idx%int(g.width)), uint16(idx/int(g.width))) {
+		n := g.index(c[0], c[1])
+		if 9 != tiles[n].value && 7 <= crowd[n]+1 {
+			return true
+		}
+	}
+	return false
+}
+
+// generateTiles with mines placed uniformly at random, never on the ignored
+// tile (or its neighbors, for a zero opening) or a protected one, by
+// shuffling just enough of the candidate tiles to pick the mines. Keeping
+// clusters apart draws again for a mine that would crowd a tile, a few times.
+func (g *Game) generateTiles(ctx context.Context, ignoreX, ignoreY uint16) ([]tile, error) {
+	tiles := make([]tile, int(g.height)*int(g.width))
+	ignore := make(map[int]bool)
+	ignore[g.index(ignoreX, ignoreY)] = true
+	for _, c := range g.options.Protected {
+		ignore[g.index(c[0], c[1])] = true
+	}
+	if g.options.ZeroOpening {
+		for _, c := range g.neighbors(ignoreX, ignoreY) {
+			ignore[g.index(c[0], c[1])] = true
+		}
+	}
+	candidates := make([]int, 0, len(tiles))
+	for idx := range tiles {
+		if !ignore[idx] {
+			candidates = append(candidates, idx)
+		}
+	}
+	if len(candidates) < int(g.mines) {
+		return nil, errors.New("protected tiles leave too few tiles for the mines")
+	}
+	// mines bordering each tile so far, when keeping clusters apart
+	var crowd []uint8
+	if g.options.NoClusters {
+		crowd = make([]uint8, len(tiles))
+	}
+	for n := 0; n < int(g.mines); n++ {
+		// large boards take a while, so check in now and then
+		if 0 == n%1024 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		pick := n + g.rng.Intn(len(candidates)-n)
+		for try := 0; g.options.NoClusters && try < clusterRetries && g.crowds(tiles, crowd, candidates[pick]); try++ {
+			pick = n + g.rng.Intn(len(candidates)-n)
+		}
+		candidates[n], candidates[pick] = candidates[pick], candidates[n]
+		tiles[candidates[n]].value = 9
+		if g.options.NoClusters {
+			for _, c := range g.neighbors(uint16(candidates[n]%int(g.width)), uint16(candidates[n]/int(g.width))) {
+				crowd[g.index(c[0], c[1])]++
+			}
+		}
+	}
+	g.countMines(tiles)
+
+	return tiles, nil
+}
+
+// countMines around every tile that isn't a mine
+func (g *Game) countMines(tiles []tile) {
 	var h, w int
 	h = int(g.height)
 	w = int(g.width)
@@ -266,80 +1208,37 @@ func (g *Game) generateTiles(ignoreX, ignoreY uint16) []tile {
 				continue
 			}
 			// total mines around this tile
-			for j := -1; j < 2; j++ {
-				for i := -1; i < 2; i++ {
-					// skip 0,0
-					if 0 == i && 0 == j {
-						continue
-					}
-					// get new x,y coords
-					y2 := int(tileY) + j
-					x2 := int(tileX) + i
-					// skip out of bounds coords
-					if 0 > x2 || 0 > y2 || x2 >= w || y2 >= h {
-						continue
-					}
-					if 9 == tiles[w*y2+x2].value {
-						tiles[idx].value++
-					}
+			for _, n := range g.neighbors(uint16(tileX), uint16(tileY)) {
+				if 9 == tiles[g.index(n[0], n[1])].value {
+					tiles[idx].value++
 				}
 			}
 		}
 	}
-
-	return tiles
 }
 
 // clickNeighbors allows an event to spread across a field of tiles
 func (g *Game) clickNeighbors(x, y uint16) {
-	var h, w int
-	h = int(g.height)
-	w = int(g.width)
-	tiles := g.history[len(g.history)-1].tiles
-	for j := -1; j < 2; j++ {
-		for i := -1; i < 2; i++ {
-			// skip 0,0
-			if 0 == i && 0 == j {
-				continue
-			}
-			// get new x,y coords
-			y2 := int(y) + j
-			x2 := int(x) + i
-			// skip out of bounds coords
-			if 0 > x2 || 0 > y2 || x2 >= w || y2 >= h {
-				continue
-			}
-			// skip neighbors that are clicked
-			tile := tiles[g.width*uint16(y2)+uint16(x2)]
-			if !tile.clicked && !tile.flagged {
-				g.ClickTile(uint16(x2), uint16(y2), false)
-			}
+	g.reveal(g.closedNeighbors(x, y))
+}
+
+// closedNeighbors of a tile, which are neither clicked nor flagged
+func (g *Game) closedNeighbors(x, y uint16) (closed [][2]uint16) {
+	for _, n := range g.neighbors(x, y) {
+		// skip neighbors that are clicked or flagged
+		tile := g.tiles[g.index(n[0], n[1])]
+		if !tile.clicked && !tile.flagged {
+			closed = append(closed, n)
 		}
 	}
+	return closed
 }
 
 // countFlags around a tile
 func (g *Game) countFlags(x, y uint16) (total uint8) {
-	var h, w int
-	h = int(g.height)
-	w = int(g.width)
-	tiles := g.history[len(g.history)-1].tiles
-	for j := -1; j < 2; j++ {
-		for i := -1; i < 2; i++ {
-			// skip 0,0
-			if 0 == i && 0 == j {
-				continue
-			}
-			// get new x,y coords
-			y2 := int(y) + j
-			x2 := int(x) + i
-			// skip out of bounds coords
-			if 0 > x2 || 0 > y2 || x2 >= w || y2 >= h {
-				continue
-			}
-			if tiles[w*y2+x2].flagged {
-				total++
-			}
+	for _, n := range g.neighbors(x, y) {
+		if g.tiles[g.index(n[0], n[1])].flagged {
+			total++
 		}
 	}
 	return total
@@ -0,0 +1,697 @@
+package mines
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// stateOf the game's latest turn, as a client would decode it
+func stateOf(t *testing.T, g *Game, v View) map[string]interface{} {
+	t.Helper()
+	js, err := g.JSONView(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var obj map[string]interface{}
+	if err = json.Unmarshal([]byte(js), &obj); err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+func TestChordOntoMisflagLoses(t *testing.T) {
+	g, err := NewGameFromLayout(4, 4, [][2]uint16{{0, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = g.ClickTile(1, 1, false); err != nil {
+		t.Fatal(err)
+	}
+	// flag the wrong neighbor, then chord the satisfied number
+	if err = g.ClickTile(2, 2, true); err != nil {
+		t.Fatal(err)
+	}
+	if err = g.ClickTile(1, 1, false); err != nil {
+		t.Fatal(err)
+	}
+	if "lost" != g.Status() || g.EndedAt().IsZero() || g.Won() {
+		t.Fatalf("game is %s, want lost", g.Status())
+	}
+	if nil == g.detonated || [2]uint16{0, 0} != *g.detonated {
+		t.Fatalf("detonated %v, want 0,0", g.detonated)
+	}
+	if err = g.ClickTile(3, 3, false); nil == err {
+		t.Fatal("lost game took another click")
+	}
+}
+
+func TestQuestionMarkCycle(t *testing.T) {
+	g, err := NewGameFromLayout(4, 4, [][2]uint16{{0, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	symbol := func() string {
+		tiles, _ := stateOf(t, g, View{})["tiles"].([]interface{})
+		return tiles[g.index(3, 3)].(string)
+	}
+	// hidden, flagged, uncertain, then hidden again
+	for i, want := range []string{"!", "Q", "?", "!"} {
+		if err = g.ClickTile(3, 3, true); err != nil {
+			t.Fatal(err)
+		}
+		if got := symbol(); want != got {
+			t.Fatalf("toggle %d shows %q, want %q", i, got, want)
+		}
+	}
+	if 1 != g.flags {
+		t.Fatalf("%d flags, want 1", g.flags)
+	}
+	// revealing an uncertain tile clears the mark
+	g.ClickTile(3, 3, true)
+	if err = g.ClickTile(3, 3, false); err != nil {
+		t.Fatal(err)
+	}
+	if tile := g.tiles[g.index(3, 3)]; !tile.clicked || tile.question {
+		t.Fatalf("uncertain tile revealed as %+v", tile)
+	}
+}
+
+func TestQuestionMarksDisabled(t *testing.T) {
+	g, err := NewGameWithOptions(4, 4, 1, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.ClickTile(3, 3, true)
+	g.ClickTile(3, 3, true)
+	if tile := g.tiles[g.index(3, 3)]; tile.flagged || tile.question {
+		t.Fatalf("unflagged tile is %+v, want hidden", tile)
+	}
+}
+
+func TestClicksAndEfficiency(t *testing.T) {
+	g, err := NewGameFromLayout(5, 5, [][2]uint16{{0, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a number clicked on its own wastes a click, as the opening would have
+	// revealed it
+	g.ClickTile(1, 1, false)
+	g.ClickTile(0, 0, true)
+	g.ClickTile(0, 0, true)
+	if 1 != g.Clicks() {
+		t.Fatalf("%d clicks, want 1 with flag toggles left out", g.Clicks())
+	}
+	g.ClickTile(4, 4, false)
+	obj := stateOf(t, g, View{})
+	if true != obj["won"] || 2.0 != obj["clicks"] || 50.0 != obj["efficiency"] {
+		t.Fatalf("won with %v clicks and %v efficiency, want 2 and 50", obj["clicks"], obj["efficiency"])
+	}
+}
+
+func TestPlayerName(t *testing.T) {
+	g, err := NewGame(9, 9, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stateOf(t, g, View{})["player"]; ok {
+		t.Fatal("unnamed game shows a player")
+	}
+	g.SetPlayerName(" ann\x00\n ")
+	if "ann" != g.PlayerName() || "ann" != stateOf(t, g, View{})["player"] {
+		t.Fatalf("player is %q, want ann", g.PlayerName())
+	}
+	// names are cut to length by character, not byte
+	g.SetPlayerName(strings.Repeat("é", 40))
+	if r := []rune(g.PlayerName()); maxPlayerName != len(r) {
+		t.Fatalf("name kept %d characters, want %d", len(r), maxPlayerName)
+	}
+}
+
+func TestNewGameFromLayout(t *testing.T) {
+	g, err := NewGameFromLayout(3, 3, [][2]uint16{{0, 0}, {2, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint8{9, 1, 0, 1, 2, 1, 0, 1, 9}
+	for i, v := range g.Solution() {
+		if want[i] != v {
+			t.Fatalf("laid out %v, want %v", g.Solution(), want)
+		}
+	}
+	if 0 != g.Seed() || g.Ranked() {
+		t.Fatalf("layout has seed %d, ranked %v", g.Seed(), g.Ranked())
+	}
+	// the first click isn't moved off a mine
+	g.ClickTile(0, 0, false)
+	if "lost" != g.Status() {
+		t.Fatalf("first click on a mine is %s", g.Status())
+	}
+	for _, mines := range [][][2]uint16{{{3, 0}}, {{0, 3}}, {{1, 1}, {1, 1}}} {
+		if _, err = NewGameFromLayout(3, 3, mines); nil == err {
+			t.Errorf("laid out %v", mines)
+		}
+	}
+}
+
+func TestWinNeedsEverySafeTileClicked(t *testing.T) {
+	g, err := NewGameFromLayout(3, 3, [][2]uint16{{0, 0}, {2, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// flagging every mine is not a win
+	g.ClickTile(0, 0, true)
+	g.ClickTile(2, 2, true)
+	g.ClickTile(1, 1, false)
+	if "active" != g.Status() {
+		t.Fatalf("game is %s with safe tiles closed", g.Status())
+	}
+	// nor is asking for one
+	g.End(true)
+	if "active" != g.Status() {
+		t.Fatalf("game ended as %s with safe tiles closed", g.Status())
+	}
+	// unflagged mines don't stop a win, once the safe tiles are open
+	g.ClickTile(2, 2, true)
+	for _, c := range [][2]uint16{{1, 0}, {2, 0}, {0, 1}, {2, 1}, {0, 2}, {1, 2}} {
+		g.ClickTile(c[0], c[1], false)
+	}
+	if "won" != g.Status() {
+		t.Fatalf("game is %s with every safe tile open", g.Status())
+	}
+}
+
+func TestFlagOnLastTileDoesNotWin(t *testing.T) {
+	g, err := NewGameFromLayout(4, 1, [][2]uint16{{1, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.ClickTile(3, 0, false)
+	// the last safe tile, flagged instead of opened
+	g.ClickTile(0, 0, true)
+	if "active" != g.Status() {
+		t.Fatalf("flagging the last safe tile left the game %s", g.Status())
+	}
+	// a flagged tile can't be opened until the flag is taken off
+	g.ClickTile(0, 0, false)
+	if "active" != g.Status() {
+		t.Fatalf("clicking a flag left the game %s", g.Status())
+	}
+	g.ClickTile(0, 0, true)
+	g.ClickTile(0, 0, true)
+	g.ClickTile(0, 0, false)
+	if "won" != g.Status() {
+		t.Fatalf("opening the last safe tile left the game %s", g.Status())
+	}
+}
+
+func TestDetonatedMine(t *testing.T) {
+	g, err := NewGameFromLayout(3, 3, [][2]uint16{{0, 0}, {2, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.ClickTile(1, 1, false)
+	if _, ok := stateOf(t, g, View{})["detonated"]; ok {
+		t.Fatal("active game names a detonated mine")
+	}
+	g.ClickTile(2, 2, false)
+	obj := stateOf(t, g, View{})
+	if d, _ := obj["detonated"].([]interface{}); 2 != len(d) || 2.0 != d[0] || 2.0 != d[1] {
+		t.Fatalf("detonated %v, want 2,2", obj["detonated"])
+	}
+	// the mine that went off shows as one, even in a concealed view
+	tiles, _ := stateOf(t, g, View{Concealed: true})["tiles"].([]interface{})
+	if "9" != tiles[8] || "?" != tiles[0] {
+		t.Fatalf("concealed loss shows %v", tiles)
+	}
+}
+
+func TestMaxDimensions(t *testing.T) {
+	defer func(w, h, m uint16) { MaxWidth, MaxHeight, MaxMines = w, h, m }(MaxWidth, MaxHeight, MaxMines)
+	MaxWidth, MaxHeight, MaxMines = 20, 10, 30
+	if _, err := NewGame(20, 10, 30); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range [][3]uint16{{21, 10, 1}, {20, 11, 1}, {20, 10, 31}, {0, 10, 1}} {
+		if _, err := NewGame(c[0], c[1], c[2]); nil == err {
+			t.Errorf("made a %dx%d board with %d mines", c[0], c[1], c[2])
+		}
+	}
+	if 30 != MaxMinesFor(20, 10) || 4 != MaxMinesFor(3, 2) {
+		t.Fatalf("max mines %d and %d", MaxMinesFor(20, 10), MaxMinesFor(3, 2))
+	}
+}
+
+func TestWrappedNeighbors(t *testing.T) {
+	g, err := NewGameWithOptions(5, 4, 1, Options{Wrap: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := g.neighbors(0, 0)
+	seen := make(map[[2]uint16]bool)
+	for _, c := range n {
+		seen[c] = true
+	}
+	if 8 != len(seen) || !seen[[2]uint16{4, 3}] || !seen[[2]uint16{4, 0}] || !seen[[2]uint16{0, 3}] {
+		t.Fatalf("corner neighbors are %v", n)
+	}
+	// the mine is counted by all 8 of its neighbors, wherever it lies
+	g, err = NewGameWithOptions(5, 4, 1, Options{Wrap: true, Seed: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var counted int
+	for _, v := range g.Solution() {
+		if 9 != v {
+			counted += int(v)
+		}
+	}
+	if 8 != counted {
+		t.Fatalf("wrapped board counts the mine %d times", counted)
+	}
+	if _, err = NewGameWithOptions(2, 5, 1, Options{Wrap: true}); nil == err {
+		t.Fatal("made a wrapped board narrower than 3")
+	}
+	if true != stateOf(t, g, View{})["wrap"] {
+		t.Fatal("wrapped board doesn't say so")
+	}
+}
+
+func TestHexNeighbors(t *testing.T) {
+	g, err := NewGameWithOptions(5, 5, 1, Options{Topology: Hex})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		x, y uint16
+		want [][2]uint16
+	}{
+		// odd rows are shifted half a tile right
+		{2, 2, [][2]uint16{{1, 1}, {2, 1}, {1, 2}, {3, 2}, {1, 3}, {2, 3}}},
+		{2, 1, [][2]uint16{{2, 0}, {3, 0}, {1, 1}, {3, 1}, {2, 2}, {3, 2}}},
+		{0, 0, [][2]uint16{{1, 0}, {0, 1}}},
+	} {
+		got := g.neighbors(c.x, c.y)
+		if !reflect.DeepEqual(c.want, got) {
+			t.Errorf("neighbors of %d,%d are %v, want %v", c.x, c.y, got, c.want)
+		}
+	}
+	if _, err = NewGameWithOptions(5, 5, 1, Options{Topology: Hex, Wrap: true}); nil == err {
+		t.Fatal("made a wrapped hex board with an odd height")
+	}
+	if _, err = NewGameWithOptions(5, 6, 1, Options{Topology: Hex, Wrap: true}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTopologyOption(t *testing.T) {
+	g, err := NewGameWithOptions(5, 5, 1, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Square != g.Options().Topology || "square" != stateOf(t, g, View{})["topology"] || 8 != len(g.neighbors(2, 2)) {
+		t.Fatalf("default topology is %q", g.Options().Topology)
+	}
+	if _, err = NewGameWithOptions(5, 5, 1, Options{Topology: "triangle"}); nil == err {
+		t.Fatal("made a board with an unknown topology")
+	}
+}
+
+func TestMinesForDensity(t *testing.T) {
+	for _, c := range []struct {
+		w, h    uint16
+		density float64
+		want    uint16
+	}{
+		{10, 10, 0.2, 20},
+		{9, 9, 0.123, 10},
+		// clamped to what the board can hold
+		{3, 3, 0.99, 7},
+		{3, 3, -1, 0},
+	} {
+		if got := MinesForDensity(c.w, c.h, c.density); c.want != got {
+			t.Errorf("%dx%d at %g has %d mines, want %d", c.w, c.h, c.density, got, c.want)
+		}
+	}
+}
+
+func TestGenerationCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewGameWithContext(ctx, 16, 16, 40, Options{Seed: 1}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("seeded board on a cancelled context got %v", err)
+	}
+	g, err := NewGame(16, 16, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = g.ClickTileContext(ctx, 8, 8, false); !errors.Is(err, context.Canceled) {
+		t.Fatalf("first click on a cancelled context got %v", err)
+	}
+	// nothing was laid out or played, so the click can be tried again
+	if g.placed || 0 != len(g.history) {
+		t.Fatal("cancelled click left the board changed")
+	}
+	if err = g.ClickTile(8, 8, false); err != nil || !g.placed {
+		t.Fatalf("retried click got %v", err)
+	}
+}
+
+func TestFlaggedList(t *testing.T) {
+	g, err := NewGameFromLayout(5, 5, [][2]uint16{{0, 0}, {4, 4}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stateOf(t, g, View{})["flagged"]; ok {
+		t.Fatal("flagged list shown without asking")
+	}
+	if got := stateOf(t, g, View{Flags: true})["flagged"]; 0 != len(got.([]interface{})) {
+		t.Fatalf("fresh board lists flags %v", got)
+	}
+	for _, c := range [][2]uint16{{4, 4}, {2, 1}, {0, 0}} {
+		if err = g.ClickTile(c[0], c[1], true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// an unflagged tile drops off the list
+	if err = g.ClickTile(2, 1, true); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := json.Marshal(stateOf(t, g, View{Flags: true})["flagged"])
+	if `[[0,0],[4,4]]` != string(got) {
+		t.Fatalf("flagged %s", got)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	g, err := NewGameFromLayout(5, 5, [][2]uint16{{0, 2}, {1, 2}, {2, 2}, {3, 2}, {4, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := stateOf(t, g, View{})["progress"]; 0.0 != p {
+		t.Fatalf("fresh board progress %v", p)
+	}
+	// the top two rows open at once
+	if err = g.ClickTile(0, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	if p := stateOf(t, g, View{})["progress"]; 0.5 != p {
+		t.Fatalf("half cleared board progress %v", p)
+	}
+	// flags are not progress
+	if err = g.ClickTile(0, 2, true); err != nil {
+		t.Fatal(err)
+	}
+	if p := stateOf(t, g, View{})["progress"]; 0.5 != p {
+		t.Fatalf("flagged board progress %v", p)
+	}
+	if err = g.ClickTile(0, 4, false); err != nil {
+		t.Fatal(err)
+	}
+	if "won" != g.Status() {
+		t.Fatalf("cleared board is %s", g.Status())
+	}
+	if p := stateOf(t, g, View{})["progress"]; 1.0 != p {
+		t.Fatalf("won board progress %v", p)
+	}
+}
+
+func TestProtectedTiles(t *testing.T) {
+	protected := [][2]uint16{{0, 0}, {4, 0}, {0, 4}, {4, 4}, {2, 0}}
+	for run := 0; run < 50; run++ {
+		// every tile left over, besides the first click, must be a mine
+		g, err := NewGameWithOptions(5, 5, 19, Options{Protected: protected})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = g.ClickTile(2, 2, false); err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range protected {
+			if 9 == g.tiles[g.index(c[0], c[1])].value {
+				t.Fatalf("run %d: mine on protected tile %d,%d", run, c[0], c[1])
+			}
+		}
+		if 9 == g.tiles[g.index(2, 2)].value {
+			t.Fatalf("run %d: mine under the first click", run)
+		}
+	}
+	// the caller can't move protected tiles once the game is made
+	g, err := NewGameWithOptions(5, 5, 19, Options{Protected: protected})
+	if err != nil {
+		t.Fatal(err)
+	}
+	protected[0] = [2]uint16{1, 1}
+	if err = g.ClickTile(2, 2, false); err != nil {
+		t.Fatal(err)
+	}
+	if 9 == g.tiles[g.index(0, 0)].value {
+		t.Fatal("protected tiles changed after the game was made")
+	}
+}
+
+func TestProtectedTilesLeaveRoom(t *testing.T) {
+	protected := [][2]uint16{{0, 0}, {4, 0}, {0, 4}, {4, 4}, {2, 0}}
+	if _, err := NewGameWithOptions(5, 5, 20, Options{Protected: protected}); nil == err {
+		t.Fatal("protected tiles crowded out the mines")
+	}
+	if _, err := NewGameWithOptions(5, 5, 5, Options{Protected: [][2]uint16{{5, 0}}}); nil == err {
+		t.Fatal("protected a tile off the board")
+	}
+}
+
+func TestJSONBeforeAnyTurn(t *testing.T) {
+	for _, o := range []Options{DefaultOptions(), {Seed: 9}} {
+		g, err := NewGameWithOptions(4, 3, 2, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tiles := stateOf(t, g, View{})["tiles"].([]interface{})
+		if 12 != len(tiles) {
+			t.Fatalf("fresh board has %d tiles", len(tiles))
+		}
+		for i, s := range tiles {
+			if DefaultSymbols.Hidden != s {
+				t.Fatalf("fresh tile %d is %q", i, s)
+			}
+		}
+	}
+}
+
+func TestStateNamesItsGame(t *testing.T) {
+	g, err := NewGame(5, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := stateOf(t, g, View{})["uuid"]; g.UUID().String() != got {
+		t.Fatalf("fresh state names %v, want %s", got, g.UUID())
+	}
+	g.ClickTile(2, 2, true)
+	js, err := g.Turn("0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var obj map[string]interface{}
+	if err = json.Unmarshal([]byte(js), &obj); err != nil {
+		t.Fatal(err)
+	}
+	if g.UUID().String() != obj["uuid"] {
+		t.Fatalf("turn names %v, want %s", obj["uuid"], g.UUID())
+	}
+}
+
+func TestMineRevealOrder(t *testing.T) {
+	g, err := NewGameFromLayout(7, 7, [][2]uint16{{0, 0}, {6, 0}, {3, 3}, {3, 4}, {5, 5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stateOf(t, g, View{})["mine_reveal_order"]; ok {
+		t.Fatal("active game has a reveal order")
+	}
+	g.ClickTile(3, 3, false)
+	// nearest first, with ties kept in row order
+	got, _ := json.Marshal(stateOf(t, g, View{})["mine_reveal_order"])
+	if `[[3,3],[3,4],[5,5],[0,0],[6,0]]` != string(got) {
+		t.Fatalf("reveal order %s", got)
+	}
+}
+
+func TestMineRevealOrderWraps(t *testing.T) {
+	g, err := NewGameFromLayout(7, 7, [][2]uint16{{0, 0}, {3, 0}, {6, 6}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// laid out boards don't wrap, but only the order is checked here
+	g.options.Wrap = true
+	g.ClickTile(0, 0, false)
+	// the far corner is a single step away across the wrapped edges
+	got, _ := json.Marshal(stateOf(t, g, View{})["mine_reveal_order"])
+	if `[[0,0],[6,6],[3,0]]` != string(got) {
+		t.Fatalf("wrapped reveal order %s", got)
+	}
+}
+
+func TestFlagAccuracy(t *testing.T) {
+	g, err := NewGameFromLayout(5, 5, [][2]uint16{{0, 0}, {4, 0}, {0, 4}, {4, 4}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range [][2]uint16{{0, 0}, {4, 0}, {2, 2}, {1, 3}, {3, 3}} {
+		if err = g.ClickTile(c[0], c[1], true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// only scored once the game is over
+	obj := stateOf(t, g, View{})
+	if _, ok := obj["flags_correct"]; ok {
+		t.Fatal("active game scores its flags")
+	}
+	g.ClickTile(4, 4, false)
+	if "lost" != g.Status() {
+		t.Fatalf("game is %s, want lost", g.Status())
+	}
+	obj = stateOf(t, g, View{})
+	if 2.0 != obj["flags_correct"] || 3.0 != obj["flags_wrong"] {
+		t.Fatalf("%v flags right and %v wrong", obj["flags_correct"], obj["flags_wrong"])
+	}
+	// a win can't have wrong flags left, as every safe tile is open
+	if g, err = NewGameFromLayout(3, 3, [][2]uint16{{0, 0}, {2, 0}}); err != nil {
+		t.Fatal(err)
+	}
+	g.ClickTile(0, 0, true)
+	g.ClickTile(1, 2, false)
+	g.ClickTile(1, 0, false)
+	obj = stateOf(t, g, View{})
+	if "won" != g.Status() || 1.0 != obj["flags_correct"] || 0.0 != obj["flags_wrong"] {
+		t.Fatalf("won game has %v flags right and %v wrong", obj["flags_correct"], obj["flags_wrong"])
+	}
+}
+
+func TestAutoChordToggle(t *testing.T) {
+	for _, chord := range []bool{true, false} {
+		g, err := NewGameFromLayout(5, 5, [][2]uint16{{0, 0}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		g.options.AutoChord = chord
+		// a satisfied number, clicked again
+		g.ClickTile(1, 1, false)
+		g.ClickTile(0, 0, true)
+		turns := len(g.history)
+		if err = g.ClickTile(1, 1, false); err != nil {
+			t.Fatal(err)
+		}
+		opened := g.tiles[g.index(2, 2)].clicked
+		if chord != opened {
+			t.Fatalf("auto chord %v opened the neighbors: %v", chord, opened)
+		}
+		if !chord && turns != len(g.history) {
+			t.Fatal("click without chording took a turn")
+		}
+	}
+}
+
+func TestJSONIsStable(t *testing.T) {
+	g, err := NewGameWithOptions(9, 9, 10, Options{Seed: 3, QuestionMarks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.ClickTile(4, 4, false)
+	g.ClickTile(0, 0, true)
+	for _, v := range []View{{}, {Flags: true, Numeric: true, Epoch: true, Timings: true}} {
+		first, err := g.JSONView(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 20; i++ {
+			if again, _ := g.JSONView(v); again != first {
+				t.Fatalf("output changed between calls:\n%s\n%s", first, again)
+			}
+		}
+		// keys come out sorted, whatever order they were set in
+		dec := json.NewDecoder(strings.NewReader(first))
+		dec.Token()
+		var keys []string
+		for dec.More() {
+			k, _ := dec.Token()
+			keys = append(keys, k.(string))
+			var skip json.RawMessage
+			dec.Decode(&skip)
+		}
+		if !sort.StringsAreSorted(keys) {
+			t.Fatalf("keys out of order: %v", keys)
+		}
+	}
+}
+
+func TestFlagFirstDefersLayout(t *testing.T) {
+	for run := 0; run < 20; run++ {
+		g, err := NewGame(5, 5, 23)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// flags before any reveal don't lay out the board
+		g.ClickTile(0, 0, true)
+		g.ClickTile(4, 4, true)
+		if g.placed || 2 != len(g.history) {
+			t.Fatalf("run %d: flags laid out the board", run)
+		}
+		// clicking a flag still isn't a reveal
+		g.ClickTile(0, 0, false)
+		if g.placed {
+			t.Fatalf("run %d: clicking a flag laid out the board", run)
+		}
+		if err = g.ClickTile(2, 2, false); err != nil {
+			t.Fatal(err)
+		}
+		// the reveal is the safe tile, and the flags stay where they were
+		if !g.placed || 9 == g.tiles[g.index(2, 2)].value {
+			t.Fatalf("run %d: first reveal wasn't safe", run)
+		}
+		if !g.tiles[g.index(0, 0)].flagged || !g.tiles[g.index(4, 4)].flagged {
+			t.Fatalf("run %d: flags lost when the board was laid out", run)
+		}
+		// with one other safe tile on a full board, flags weren't kept safe
+		if 9 != g.tiles[g.index(0, 0)].value && 9 != g.tiles[g.index(4, 4)].value {
+			t.Fatalf("run %d: flagged tiles were kept safe", run)
+		}
+	}
+}
+
+func TestNoMinesWinsAtOnce(t *testing.T) {
+	for _, o := range []Options{DefaultOptions(), {Seed: 4}, {Topology: Hex}} {
+		g, err := NewGameWithOptions(6, 4, 0, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = g.ClickTile(1, 3, false); err != nil {
+			t.Fatal(err)
+		}
+		for i, tile := range g.tiles {
+			if 0 != tile.value || !tile.clicked {
+				t.Fatalf("tile %d is %+v", i, tile)
+			}
+		}
+		if "won" != g.Status() || 1 != len(g.history) {
+			t.Fatalf("empty board is %s after %d turns", g.Status(), len(g.history))
+		}
+	}
+}
+
+func TestTooManyMines(t *testing.T) {
+	_, err := NewGame(20, 20, 400)
+	var tooMany *TooManyMinesError
+	if !errors.As(err, &tooMany) || 398 != tooMany.Max {
+		t.Fatalf("400 mines on 20x20: %v", err)
+	}
+	if "mines 400 exceed maximum 398 for 20x20" != err.Error() {
+		t.Fatalf("error reads %q", err)
+	}
+	if _, err = NewGame(20, 20, 398); err != nil {
+		t.Fatalf("the most mines that fit: %v", err)
+	}
+}
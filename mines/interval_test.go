@@ -0,0 +1,85 @@
+package mines
+
+import (
+	"testing"
+	"time"
+)
+
+// pacedGame on a 7x7 board with two provable mines, (1,1) and (5,5), once
+// the center is opened, and room for one turn a second
+func pacedGame(t *testing.T) (*Game, *manualClock) {
+	t.Helper()
+	g, err := NewGameFromLayout(7, 7, [][2]uint16{{0, 0}, {1, 1}, {5, 5}, {6, 6}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk := &manualClock{t: time.Unix(5000, 0)}
+	g.options.Clock = clk
+	g.options.MinInterval = time.Second
+	if err = g.ClickTile(3, 3, false); err != nil {
+		t.Fatal(err)
+	}
+	return g, clk
+}
+
+func TestMinIntervalRefusesFastClicks(t *testing.T) {
+	g, clk := pacedGame(t)
+	clk.t = clk.t.Add(500 * time.Millisecond)
+	if err := g.ClickTile(1, 1, true); ErrTooFast != err {
+		t.Fatalf("got %v, want ErrTooFast", err)
+	}
+	clk.t = clk.t.Add(500 * time.Millisecond)
+	if err := g.ClickTile(1, 1, true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMinIntervalOncePerAction(t *testing.T) {
+	g, clk := pacedGame(t)
+	clk.t = clk.t.Add(time.Second)
+	// a question mark takes two clicks to turn into a flag
+	g.tiles[g.index(5, 5)].question = true
+	flagged, err := g.AutoFlag()
+	if err != nil || 2 != len(flagged) {
+		t.Fatalf("flagged %v, %v", flagged, err)
+	}
+	if !g.tiles[g.index(5, 5)].flagged || g.tiles[g.index(5, 5)].question {
+		t.Fatal("question mark was left half flagged")
+	}
+	clk.t = clk.t.Add(time.Second)
+	if _, err = g.ApplyMoves([]Move{{X: 1, Y: 1, Flag: true}, {X: 5, Y: 5, Flag: true}}); err != nil {
+		t.Fatal(err)
+	}
+	if g.tiles[g.index(1, 1)].flagged || g.tiles[g.index(5, 5)].flagged {
+		t.Fatal("moves were not all applied")
+	}
+	// but the next action still has to wait
+	if _, err = g.ApplyMoves([]Move{{X: 1, Y: 1, Flag: true}}); ErrTooFast != err {
+		t.Fatalf("got %v, want ErrTooFast", err)
+	}
+	if _, err = g.FlagTiles([][2]uint16{{1, 1}}); ErrTooFast != err {
+		t.Fatalf("got %v, want ErrTooFast", err)
+	}
+	if _, err = g.AutoFlag(); ErrTooFast != err {
+		t.Fatalf("got %v, want ErrTooFast", err)
+	}
+}
+
+func TestSignedExportKeepsInterval(t *testing.T) {
+	key := []byte("key")
+	g, err := NewGameWithOptions(9, 9, 10, Options{MinInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = g.ClickTile(4, 4, true); err != nil {
+		t.Fatal(err)
+	}
+	blob, _ := g.SignedExport(key)
+	r, err := VerifiedImport(blob, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = r.ClickTile(4, 4, false); ErrTooFast != err {
+		t.Fatalf("resumed click got %v, want ErrTooFast", err)
+	}
+}
@@ -0,0 +1,94 @@
+package mines
+
+import (
+	"context"
+	"errors"
+)
+
+// Move is a single click, as sent in a batch
+type Move struct {
+	X    uint16 `json:"x"`
+	Y    uint16 `json:"y"`
+	Flag bool   `json:"flag"`
+}
+
+// ApplyMoves in order, stopping at the first error or once the game ends.
+// The batch is one action, so MinInterval only holds it apart from the turn
+// before it.
+func (g *Game) ApplyMoves(moves []Move) (applied int, err error) {
+	if 0 == len(moves) || !g.endedAt.IsZero() {
+		return 0, nil
+	}
+	// shared games taking turns need to know who is clicking
+	if g.options.TakeTurns && 0 < len(g.members) {
+		return 0, errors.New("player token required")
+	}
+	if err = g.paced(); err != nil {
+		return 0, err
+	}
+	for _, m := range moves {
+		if !g.endedAt.IsZero() {
+			break
+		}
+		if err = g.click(context.Background(), m.X, m.Y, m.Flag); err != nil {
+			return applied, err
+		}
+		applied++
+	}
+	return applied, nil
+}
+
+// FlagTiles flags every listed tile as a single turn, leaving tiles already
+// flagged alone and clearing question marks. A tile that can't be flagged is
+// reported in its place in results without stopping the rest; err is only
+// set when the game refuses the whole list.
+func (g *Game) FlagTiles(coords [][2]uint16) (results []error, err error) {
+	if !g.endedAt.IsZero() {
+		return nil, errors.New("Game is not active")
+	}
+	if g.Paused() {
+		return nil, errors.New("Game is paused")
+	}
+	// shared games taking turns need to know who is clicking
+	if g.options.TakeTurns && 0 < len(g.members) {
+		return nil, errors.New("player token required")
+	}
+	if err = g.paced(); err != nil {
+		return nil, err
+	}
+	results = make([]error, len(coords))
+	if 0 == len(coords) {
+		return results, nil
+	}
+	turn, err := newTurn(coords[0][0], coords[0][1], true, g.now())
+	if err != nil {
+		return nil, err
+	}
+	// marks made before the mines are laid out go on a blank board
+	if nil == g.tiles {
+		g.tiles = make([]tile, int(g.height)*int(g.width))
+	}
+	g.history[len(g.history)] = turn
+	for i, c := range coords {
+		if results[i] = g.canClick(c[0], c[1]); results[i] != nil {
+			continue
+		}
+		idx := g.index(c[0], c[1])
+		if g.tiles[idx].clicked {
+			results[i] = errors.New("Tile is already revealed")
+			continue
+		}
+		if g.tiles[idx].flagged {
+			continue
+		}
+		t := g.edit(idx)
+		t.flagged = true
+		t.question = false
+		g.flags++
+	}
+	// drop turns that changed nothing, so replays stay meaningful
+	if 0 == len(turn.changes) {
+		delete(g.history, len(g.history)-1)
+	}
+	return results, nil
+}
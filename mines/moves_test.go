@@ -0,0 +1,107 @@
+package mines
+
+import (
+	"testing"
+)
+
+func TestApplyMovesWins(t *testing.T) {
+	g, err := NewGameFromLayout(4, 1, [][2]uint16{{1, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied, err := g.ApplyMoves([]Move{{X: 1, Y: 0, Flag: true}, {X: 3, Y: 0}, {X: 0, Y: 0}})
+	if err != nil || 3 != applied {
+		t.Fatalf("applied %d: %v", applied, err)
+	}
+	if "won" != g.Status() {
+		t.Fatalf("game is %s, want won", g.Status())
+	}
+}
+
+func TestApplyMovesStopsOnLoss(t *testing.T) {
+	g, err := NewGameFromLayout(4, 1, [][2]uint16{{1, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// nothing after the mine is played
+	applied, err := g.ApplyMoves([]Move{{X: 3, Y: 0}, {X: 1, Y: 0}, {X: 0, Y: 0}})
+	if err != nil || 2 != applied {
+		t.Fatalf("applied %d: %v", applied, err)
+	}
+	if "lost" != g.Status() || g.tiles[0].clicked {
+		t.Fatalf("game is %s, tile 0 clicked %v", g.Status(), g.tiles[0].clicked)
+	}
+	if applied, err = g.ApplyMoves([]Move{{X: 0, Y: 0}}); err != nil || 0 != applied {
+		t.Fatalf("lost game applied %d: %v", applied, err)
+	}
+}
+
+func TestApplyMovesBadCoordinate(t *testing.T) {
+	g, err := NewGameFromLayout(4, 1, [][2]uint16{{1, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied, err := g.ApplyMoves([]Move{{X: 3, Y: 0}, {X: 4, Y: 0}, {X: 0, Y: 0}})
+	if nil == err || 1 != applied {
+		t.Fatalf("applied %d: %v", applied, err)
+	}
+	// the moves before the bad one stand
+	if !g.tiles[3].clicked || g.tiles[0].clicked || "active" != g.Status() {
+		t.Fatalf("game is %s after a bad move", g.Status())
+	}
+}
+
+func TestFlagTilesIsOneTurn(t *testing.T) {
+	// opening the center leaves the corners closed
+	g, err := NewGameFromLayout(7, 7, [][2]uint16{{0, 0}, {1, 1}, {5, 5}, {6, 6}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = g.ClickTile(3, 3, false); err != nil {
+		t.Fatal(err)
+	}
+	g.tiles[g.index(5, 5)].question = true
+	turns := len(g.history)
+	results, err := g.FlagTiles([][2]uint16{{0, 0}, {5, 5}, {3, 3}, {9, 9}, {0, 0}, {1, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, failed := range []bool{false, false, true, true, false, false} {
+		if failed != (nil != results[i]) {
+			t.Fatalf("result %d is %v", i, results[i])
+		}
+	}
+	if turns+1 != len(g.history) {
+		t.Fatalf("took %d turns, want 1", len(g.history)-turns)
+	}
+	for _, c := range [][2]uint16{{0, 0}, {5, 5}, {1, 1}} {
+		if tile := g.tiles[g.index(c[0], c[1])]; !tile.flagged || tile.question {
+			t.Fatalf("tile %v is %+v, want flagged", c, tile)
+		}
+	}
+	if 3 != g.flags {
+		t.Fatalf("counted %d flags, want 3", g.flags)
+	}
+	// the whole batch replays, and undoes, as one
+	if before := g.tilesAt(turns - 1); before[g.index(0, 0)].flagged || !before[g.index(5, 5)].question {
+		t.Fatal("turn before the batch shows its flags")
+	}
+	// flagging again changes nothing, so takes no turn
+	if _, err = g.FlagTiles([][2]uint16{{0, 0}}); err != nil || turns+1 != len(g.history) {
+		t.Fatalf("repeat took a turn: %v", err)
+	}
+}
+
+func TestFlagTilesBeforeLayout(t *testing.T) {
+	g, _ := NewGame(9, 9, 10)
+	if _, err := g.FlagTiles([][2]uint16{{1, 1}, {2, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if g.placed || 2 != g.flags || 1 != len(g.history) {
+		t.Fatal("flags before the first reveal were not kept on a blank board")
+	}
+	g.Pause()
+	if _, err := g.FlagTiles([][2]uint16{{3, 3}}); nil == err {
+		t.Fatal("paused game took flags")
+	}
+}
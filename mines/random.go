@@ -0,0 +1,25 @@
+package mines
+
+import (
+	crand "crypto/rand"
+	"math/big"
+)
+
+// Generator of random numbers for mine placement, satisfied by *rand.Rand
+type Generator interface {
+	Intn(n int) int
+}
+
+// cryptoGenerator draws from crypto/rand, so upcoming boards can't be
+// predicted by observing earlier ones
+type cryptoGenerator struct{}
+
+// Intn returns a number in [0,n)
+func (cryptoGenerator) Intn(n int) int {
+	v, err := crand.Int(crand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		// crypto/rand only fails when the OS can't supply randomness
+		panic(err)
+	}
+	return int(v.Int64())
+}
@@ -0,0 +1,222 @@
+package mines
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFreshGamesDealDifferentBoards(t *testing.T) {
+	a, err := NewGame(16, 16, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewGame(16, 16, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if 0 == a.Seed() || a.Seed() == b.Seed() {
+		t.Fatalf("games drew seeds %d and %d", a.Seed(), b.Seed())
+	}
+	a.ClickTile(8, 8, false)
+	b.ClickTile(8, 8, false)
+	if bytes.Equal(a.Solution(), b.Solution()) {
+		t.Fatal("two fresh games dealt the same board")
+	}
+}
+
+func TestSameSeedDealsSameBoard(t *testing.T) {
+	deal := func() []uint8 {
+		g, err := NewGameWithOptions(16, 16, 40, Options{Seed: 42})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return g.Solution()
+	}
+	if !bytes.Equal(deal(), deal()) {
+		t.Fatal("one seed dealt two boards")
+	}
+}
+
+func TestSeededBoardSurvivesReload(t *testing.T) {
+	control, err := NewGameWithOptions(16, 16, 40, Options{Seed: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := NewGameWithOptions(16, 16, 40, Options{Seed: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the board is already dealt before the first click
+	code, err := g.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := ImportGame(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if 0 != reloaded.Seed() {
+		t.Fatalf("reloaded board claims seed %d", reloaded.Seed())
+	}
+	// first clicks anywhere but the center still find the same board
+	control.ClickTile(3, 12, false)
+	reloaded.ClickTile(3, 12, false)
+	if !bytes.Equal(control.Solution(), reloaded.Solution()) {
+		t.Fatal("reloaded game dealt a different board")
+	}
+	for i := range control.tiles {
+		if control.tiles[i].clicked != reloaded.tiles[i].clicked {
+			t.Fatalf("tile %d opened differently after reload", i)
+		}
+	}
+}
+
+func TestCryptoGeneratorInRange(t *testing.T) {
+	var seen [7]bool
+	for i := 0; i < 1000; i++ {
+		n := cryptoGenerator{}.Intn(7)
+		if 0 > n || 7 <= n {
+			t.Fatalf("Intn(7) gave %d", n)
+		}
+		seen[n] = true
+	}
+	for n, ok := range seen {
+		if !ok {
+			t.Fatalf("Intn(7) never gave %d", n)
+		}
+	}
+}
+
+func TestSecureBoard(t *testing.T) {
+	g, err := NewGameWithOptions(16, 16, 40, Options{Secure: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if 0 != g.Seed() {
+		t.Fatalf("secure board has seed %d", g.Seed())
+	}
+	if err = g.ClickTile(8, 8, false); err != nil {
+		t.Fatal(err)
+	}
+	var mines int
+	for _, v := range g.Solution() {
+		if 9 == v {
+			mines++
+		}
+	}
+	if 40 != mines || "lost" == g.Status() {
+		t.Fatalf("secure board laid out %d mines, first click %s", mines, g.Status())
+	}
+	if _, err = NewGameWithOptions(16, 16, 40, Options{Secure: true, Seed: 1}); nil == err {
+		t.Fatal("made a seeded secure board")
+	}
+}
+
+func TestPlacementIsUniform(t *testing.T) {
+	const runs = 8000
+	var hits [16]int
+	for seed := int64(1); seed <= runs; seed++ {
+		g, err := NewGameWithOptions(4, 4, 8, Options{Seed: seed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		mines := 0
+		for i, tile := range g.tiles {
+			if 9 == tile.value {
+				hits[i]++
+				mines++
+			}
+		}
+		if 8 != mines {
+			t.Fatalf("seed %d placed %d mines", seed, mines)
+		}
+	}
+	// the center is safe, and every other tile is as likely as the next
+	center := 2*4 + 2
+	want := runs * 8 / 15
+	for i, n := range hits {
+		if center == i {
+			if 0 != n {
+				t.Fatalf("safe center held %d mines", n)
+			}
+		} else if n < want*95/100 || want*105/100 < n {
+			t.Fatalf("tile %d held a mine %d times, want about %d", i, n, want)
+		}
+	}
+}
+
+func TestPlacementFillsDenseBoards(t *testing.T) {
+	m := MaxMinesFor(5, 5)
+	for seed := int64(1); seed <= 100; seed++ {
+		g, err := NewGameWithOptions(5, 5, m, Options{Seed: seed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		mines := 0
+		for _, tile := range g.tiles {
+			if 9 == tile.value {
+				mines++
+			}
+		}
+		if int(m) != mines || 9 == g.tiles[g.index(2, 2)].value {
+			t.Fatalf("seed %d placed %d mines, center %d", seed, mines, g.tiles[g.index(2, 2)].value)
+		}
+	}
+}
+
+// crowded safe tiles, bordering 7 or 8 mines, and the mines on the board
+func crowded(g *Game) (crowded, mines int) {
+	for _, tile := range g.tiles {
+		if 9 == tile.value {
+			mines++
+		} else if 7 <= tile.value {
+			crowded++
+		}
+	}
+	return crowded, mines
+}
+
+func TestNoClusters(t *testing.T) {
+	var without int
+	for seed := int64(1); seed <= 100; seed++ {
+		g, err := NewGameWithOptions(10, 10, 40, Options{Seed: seed, NoClusters: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, mines := crowded(g); 0 != n || 40 != mines {
+			t.Fatalf("seed %d: %d crowded tiles among %d mines", seed, n, mines)
+		}
+		// the same seeds crowd tiles without the option
+		if g, err = NewGameWithOptions(10, 10, 40, Options{Seed: seed}); err != nil {
+			t.Fatal(err)
+		}
+		n, _ := crowded(g)
+		without += n
+	}
+	if 0 == without {
+		t.Fatal("boards never crowded a tile, so the option went untested")
+	}
+}
+
+func TestNoClustersShownOnPlay(t *testing.T) {
+	for run := 0; run < 20; run++ {
+		g, err := NewGameWithOptions(10, 10, 45, Options{NoClusters: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		g.ClickTile(5, 5, false)
+		for idx := range g.tiles {
+			if 9 != g.tiles[idx].value {
+				g.ClickTile(uint16(idx%10), uint16(idx/10), false)
+			}
+		}
+		if "won" != g.Status() {
+			t.Fatalf("run %d: game is %s after every safe tile", run, g.Status())
+		}
+		for i, s := range stateOf(t, g, View{})["tiles"].([]interface{}) {
+			if "7" == s || "8" == s {
+				t.Fatalf("run %d: tile %d shows %s", run, i, s)
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package mines
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// String renders the latest turn as ASCII, using the same symbols as JSON
+func (g *Game) String() string {
+	return g.render(false)
+}
+
+// render the latest turn, optionally revealing every tile
+func (g *Game) render(all bool) string {
+	var b strings.Builder
+	g.writeGrid(&b, all)
+	return b.String()
+}
+
+// WriteGrid streams the latest turn as ASCII, one row at a time
+func (g *Game) WriteGrid(w io.Writer) error {
+	return g.writeGrid(w, false)
+}
+
+// WriteSolution streams every tile value as ASCII, one row at a time
+func (g *Game) WriteSolution(w io.Writer) error {
+	return g.writeGrid(w, true)
+}
+
+// writeGrid of the latest turn, optionally revealing every tile
+func (g *Game) writeGrid(out io.Writer, all bool) error {
+	tiles := g.tiles
+	var h, w int
+	h = int(g.height)
+	w = int(g.width)
+	// pad labels to the widest index
+	colW := len(strconv.Itoa(w - 1))
+	rowW := len(strconv.Itoa(h - 1))
+	// only a single row is held at a time
+	var b bytes.Buffer
+	// column labels
+	b.WriteString(strings.Repeat(" ", rowW))
+	for x := 0; x < w; x++ {
+		fmt.Fprintf(&b, " %*d", colW, x)
+	}
+	b.WriteString("\n")
+	for y := 0; y < h; y++ {
+		// row label
+		fmt.Fprintf(&b, "%*d", rowW, y)
+		for x := 0; x < w; x++ {
+			var val string
+			if nil == tiles || (all && !g.placed) { // board is not generated yet
+				val = "?"
+			} else if all {
+				val = strconv.Itoa(int(tiles[w*y+x].value))
+			} else {
+				val = g.symbol(tiles[w*y+x])
+			}
+			// empty open tiles need something to show
+			if "" == val || (all && "0" == val) {
+				val = "."
+			}
+			fmt.Fprintf(&b, " %*s", colW, val)
+		}
+		b.WriteString("\n")
+		if _, err := b.WriteTo(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package mines
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestStringShowsEachState(t *testing.T) {
+	g, err := NewGameFromLayout(3, 2, [][2]uint16{{0, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name  string
+		click func()
+		want  string
+	}{
+		{"unopened", func() {}, "  0 1 2\n0 ? ? ?\n1 ? ? ?\n"},
+		{"active", func() { g.ClickTile(2, 0, false) }, "  0 1 2\n0 ? 1 .\n1 ? 1 .\n"},
+		{"won", func() { g.ClickTile(0, 1, false) }, "  0 1 2\n0 * 1 .\n1 1 1 .\n"},
+	} {
+		c.click()
+		if got := g.String(); c.want != got {
+			t.Errorf("%s board:\n%s\nwant:\n%s", c.name, got, c.want)
+		}
+	}
+	lost, err := NewGameFromLayout(3, 2, [][2]uint16{{0, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lost.ClickTile(1, 0, false)
+	lost.ClickTile(2, 1, true)
+	lost.ClickTile(0, 0, false)
+	if want, got := "  0 1 2\n0 9 1 ?\n1 ? ? X\n", lost.String(); want != got {
+		t.Errorf("lost board:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestStringPadsWideLabels(t *testing.T) {
+	g, err := NewGame(12, 11, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.ClickTile(5, 5, false)
+	lines := strings.Split(strings.TrimSuffix(g.String(), "\n"), "\n")
+	if 12 != len(lines) {
+		t.Fatalf("rendered %d lines, want a header and 11 rows", len(lines))
+	}
+	// two characters of row label, and three per column
+	for i, line := range lines {
+		if 2+12*3 != len(line) {
+			t.Fatalf("line %d is %d wide: %q", i, len(line), line)
+		}
+	}
+	if !strings.HasSuffix(lines[0], " 11") || !strings.HasPrefix(lines[1], " 0 ") || !strings.HasPrefix(lines[11], "10 ") {
+		t.Fatalf("labels misaligned:\n%s", g.String())
+	}
+}
+
+// failingWriter accepts n writes, then fails
+type failingWriter struct{ n int }
+
+func (f *failingWriter) Write(b []byte) (int, error) {
+	if 0 == f.n {
+		return 0, errors.New("closed")
+	}
+	f.n--
+	return len(b), nil
+}
+
+func TestWriteGridStreamsRows(t *testing.T) {
+	g, err := NewGameFromLayout(3, 2, [][2]uint16{{0, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err = g.WriteGrid(&b); err != nil || "  0 1 2\n0 ? ? ?\n1 ? ? ?\n" != b.String() {
+		t.Fatalf("streamed %q: %v", b.String(), err)
+	}
+	g.ClickTile(2, 0, false)
+	b.Reset()
+	if g.WriteGrid(&b); g.String() != b.String() {
+		t.Fatalf("streamed %q, String is %q", b.String(), g.String())
+	}
+	// the solution shows every value, before the game is over
+	b.Reset()
+	if err = g.WriteSolution(&b); err != nil || "  0 1 2\n0 9 1 .\n1 1 1 .\n" != b.String() {
+		t.Fatalf("streamed solution %q: %v", b.String(), err)
+	}
+	// the header goes out with the first row, so the second write fails
+	if err = g.WriteGrid(&failingWriter{n: 1}); nil == err {
+		t.Fatal("write error was lost")
+	}
+}
@@ -0,0 +1,204 @@
+package mines
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// recursiveReveal opens tiles the way the flood fill did before it kept its
+// own stack, to check the two agree
+func recursiveReveal(g *Game, tiles []tile, x, y uint16) {
+	t := &tiles[g.index(x, y)]
+	if t.clicked || t.flagged {
+		return
+	}
+	t.clicked = true
+	if 0 != t.value {
+		return
+	}
+	for _, n := range g.neighbors(x, y) {
+		recursiveReveal(g, tiles, n[0], n[1])
+	}
+}
+
+func TestRevealMatchesRecursiveFill(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for run := 0; run < 200; run++ {
+		o := Options{Seed: int64(run + 1), Wrap: 0 == run%3}
+		if 1 == run%3 {
+			o.Topology = Hex
+		}
+		g, err := NewGameWithOptions(30, 30, 60, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// flags fence parts of the openings off
+		for i := 0; i < 20; i++ {
+			g.ClickTile(uint16(r.Intn(30)), uint16(r.Intn(30)), true)
+		}
+		want := make([]tile, len(g.tiles))
+		copy(want, g.tiles)
+		recursiveReveal(g, want, 15, 15)
+		g.ClickTile(15, 15, false)
+		for i := range want {
+			if want[i].clicked != g.tiles[i].clicked {
+				t.Fatalf("run %d: tile %d revealed %v, recursive fill %v", run, i, g.tiles[i].clicked, want[i].clicked)
+			}
+		}
+	}
+}
+
+func TestRevealLargeOpening(t *testing.T) {
+	g, err := NewGameFromLayout(MaxWidth, MaxHeight, [][2]uint16{{MaxWidth - 1, MaxHeight - 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = g.ClickTile(0, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	if "won" != g.Status() {
+		t.Fatalf("game is %s, want won", g.Status())
+	}
+}
+
+func BenchmarkRevealLargeOpening(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		g, err := NewGameFromLayout(MaxWidth, MaxHeight, [][2]uint16{{MaxWidth - 1, MaxHeight - 1}})
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+		g.ClickTile(0, 0, false)
+	}
+}
+
+func TestFloodRevealsBorderOnly(t *testing.T) {
+	// a wall of mines down column 2, so an opening on the left is bordered
+	// by the numbers in column 1
+	var wall [][2]uint16
+	for y := uint16(0); y < 5; y++ {
+		wall = append(wall, [2]uint16{2, y})
+	}
+	g, err := NewGameFromLayout(6, 5, wall)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = g.ClickTile(0, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	for y := uint16(0); y < 5; y++ {
+		for x := uint16(0); x < 6; x++ {
+			if want, got := 2 > x, g.tiles[g.index(x, y)].clicked; want != got {
+				t.Fatalf("tile %d,%d revealed %v, want %v", x, y, got, want)
+			}
+		}
+	}
+	if "active" != g.Status() {
+		t.Fatalf("game is %s, want active", g.Status())
+	}
+}
+
+func TestFloodLeavesFlagsClosed(t *testing.T) {
+	g, err := NewGameFromLayout(5, 5, [][2]uint16{{4, 4}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.ClickTile(0, 4, true)
+	g.ClickTile(0, 0, false)
+	if g.tiles[g.index(0, 4)].clicked {
+		t.Fatal("flood opened a flagged tile")
+	}
+	if !g.tiles[g.index(3, 3)].clicked || 1 != g.tiles[g.index(3, 3)].value {
+		t.Fatal("flood stopped short of the border")
+	}
+}
+
+func TestFloodNeverDetonates(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for run := 0; run < 300; run++ {
+		o := DefaultOptions()
+		o.Seed = int64(run + 1)
+		o.Wrap = 0 == run%3
+		if 1 == run%3 {
+			o.Topology = Hex
+		}
+		g, err := NewGameWithOptions(16, 16, 40, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// open every empty tile left closed, in a random order, so each
+		// starts a flood of its own
+		for _, idx := range r.Perm(len(g.tiles)) {
+			if 0 != g.tiles[idx].value || g.tiles[idx].clicked {
+				continue
+			}
+			g.ClickTile(uint16(idx%16), uint16(idx/16), false)
+			if "lost" == g.Status() {
+				t.Fatalf("run %d: flood from %d detonated %v", run, idx, *g.detonated)
+			}
+		}
+		for idx := range g.tiles {
+			if 9 == g.tiles[idx].value && g.tiles[idx].clicked {
+				t.Fatalf("run %d: flood opened mine %d", run, idx)
+			}
+		}
+	}
+}
+
+// opened tiles on the board
+func opened(g *Game) (n int) {
+	for _, t := range g.tiles {
+		if t.clicked {
+			n++
+		}
+	}
+	return n
+}
+
+func TestCascadeLimit(t *testing.T) {
+	for seed := int64(1); seed <= 20; seed++ {
+		g, err := NewGameWithOptions(20, 20, 10, Options{Seed: seed, ZeroOpening: true, CascadeLimit: 50})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = g.ContinueReveal(); nil == err {
+			t.Fatal("continued a reveal that never started")
+		}
+		// the same board, opened all at once
+		whole, err := NewGameWithOptions(20, 20, 10, Options{Seed: seed, ZeroOpening: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		whole.ClickTile(10, 10, false)
+		g.ClickTile(10, 10, false)
+		if 50 < opened(whole) && (50 != opened(g) || 0 == len(g.Frontier())) {
+			t.Fatalf("seed %d: opened %d tiles, leaving %d on the frontier", seed, opened(g), len(g.Frontier()))
+		}
+		if f, _ := stateOf(t, g, View{})["frontier"].([]interface{}); len(g.Frontier()) != len(f) {
+			t.Fatalf("seed %d: state frontier %v", seed, f)
+		}
+		// every follow up opens no more than the limit, until the opening
+		// is as big as it would have been
+		for steps := 0; 0 < len(g.Frontier()); steps++ {
+			before := opened(g)
+			if err = g.ContinueReveal(); err != nil {
+				t.Fatal(err)
+			}
+			if n := opened(g) - before; 0 == n || 50 < n {
+				t.Fatalf("seed %d: step %d opened %d tiles", seed, steps, n)
+			}
+		}
+		for i := range g.tiles {
+			if g.tiles[i].clicked != whole.tiles[i].clicked {
+				t.Fatalf("seed %d: tile %d opened differently in steps", seed, i)
+			}
+		}
+		if whole.Status() != g.Status() || 1 != g.Clicks() {
+			t.Fatalf("seed %d: game is %s after %d clicks", seed, g.Status(), g.Clicks())
+		}
+		if _, ok := stateOf(t, g, View{})["frontier"]; ok {
+			t.Fatalf("seed %d: finished reveal shows a frontier", seed)
+		}
+	}
+}
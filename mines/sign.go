@@ -0,0 +1,60 @@
+package mines
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+// SignedExport seals an active game under secret with AES-GCM, as URL-safe
+// base64 of the nonce followed by the ciphertext. The client holding it can
+// neither read the mines nor edit the game without VerifiedImport noticing.
+func (g *Game) SignedExport(secret []byte) (string, error) {
+	state, err := g.exportState()
+	if err != nil {
+		return "", err
+	}
+	aead, err := stateCipher(secret)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, state, nil)), nil
+}
+
+// VerifiedImport continues a game from a SignedExport code, refusing any
+// code not sealed under secret
+func VerifiedImport(signed string, secret []byte) (*Game, error) {
+	b, err := base64.RawURLEncoding.DecodeString(signed)
+	if err != nil {
+		return nil, errors.New("game code is not signed")
+	}
+	aead, err := stateCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < aead.NonceSize() {
+		return nil, errors.New("game code is not signed")
+	}
+	state, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.New("game code signature does not match")
+	}
+	return importState(state)
+}
+
+// stateCipher sealing exported states, keyed from secret
+func stateCipher(secret []byte) (cipher.AEAD, error) {
+	key := sha256.Sum256(secret)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
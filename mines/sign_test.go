@@ -0,0 +1,104 @@
+package mines
+
+import (
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignedExportRoundTrip(t *testing.T) {
+	key := []byte("key")
+	o := Options{
+		Seed:         7,
+		TakeTurns:    true,
+		Protected:    [][2]uint16{{0, 0}},
+		CascadeLimit: 5,
+		MinInterval:  time.Millisecond,
+	}
+	g, err := NewGameWithOptions(9, 9, 10, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := g.Join("ann")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = g.ClickTileAs(token, 1, 1, true); err != nil {
+		t.Fatal(err)
+	}
+	blob, err := g.SignedExport(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := VerifiedImport(blob, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r.tiles, g.tiles) || r.flags != g.flags {
+		t.Fatal("tiles differ after import")
+	}
+	if r.seed != g.seed || !r.options.TakeTurns || 5 != r.options.CascadeLimit ||
+		time.Millisecond != r.options.MinInterval || !reflect.DeepEqual(r.options.Protected, o.Protected) {
+		t.Fatalf("options lost on import: %+v", r.options)
+	}
+	// the joined player keeps playing, so turns can't be skipped
+	if err = r.ClickTile(4, 4, false); nil == err {
+		t.Fatal("click without a player token was taken")
+	}
+}
+
+func TestSignedExportUnplacedKeepsSeed(t *testing.T) {
+	key := []byte("key")
+	g, err := NewGame(16, 16, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob, err := g.SignedExport(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := VerifiedImport(blob, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a resumed board is the one the original would have dealt
+	g.ClickTile(3, 3, false)
+	r.ClickTile(3, 3, false)
+	for i := range g.tiles {
+		if g.tiles[i].value != r.tiles[i].value {
+			t.Fatal("resumed game dealt a different board")
+		}
+	}
+}
+
+func TestSignedExportSealed(t *testing.T) {
+	key := []byte("key")
+	g, _ := NewGameWithOptions(9, 9, 10, Options{Seed: 2})
+	g.ClickTile(4, 4, false)
+	blob, err := g.SignedExport(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := base64.RawURLEncoding.DecodeString(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "tiles") {
+		t.Fatal("blob is readable")
+	}
+	if _, err = VerifiedImport(blob, []byte("other")); nil == err {
+		t.Fatal("blob opened under another key")
+	}
+	for i := range b {
+		c := append([]byte(nil), b...)
+		c[i] ^= 1
+		if _, err = VerifiedImport(base64.RawURLEncoding.EncodeToString(c), key); nil == err {
+			t.Fatalf("edited byte %d went unnoticed", i)
+		}
+	}
+	if _, err = VerifiedImport("not a blob!", key); nil == err {
+		t.Fatal("garbage was imported")
+	}
+}
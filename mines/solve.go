@@ -0,0 +1,204 @@
+package mines
+
+import (
+	"context"
+	"errors"
+)
+
+// Solve deduces which hidden tiles are provably safe and which are provably
+// mines, using only what is visible on the latest turn
+func (g *Game) Solve() (safe, mines [][2]uint16) {
+	if !g.placed {
+		return nil, nil
+	}
+	w := int(g.width)
+	tiles := g.tiles
+	// deduced state of each tile: 0 unknown, 1 safe, 2 mine
+	known := make([]uint8, len(tiles))
+	for changed := true; changed; {
+		changed = false
+		for idx := 0; idx < len(tiles); idx++ {
+			// only revealed numbers give us information
+			if !tiles[idx].clicked || 9 == tiles[idx].value {
+				continue
+			}
+			tileX := idx % w
+			tileY := idx / w
+			var found uint8
+			var unknown []int
+			for _, n := range g.neighbors(uint16(tileX), uint16(tileY)) {
+				idx2 := g.index(n[0], n[1])
+				if tiles[idx2].clicked {
+					continue
+				}
+				switch known[idx2] {
+				case 0:
+					unknown = append(unknown, idx2)
+				case 2:
+					found++
+				}
+			}
+			if 0 == len(unknown) {
+				continue
+			}
+			if tiles[idx].value == found { // every mine is accounted for
+				for _, u := range unknown {
+					known[u] = 1
+				}
+				changed = true
+			} else if int(tiles[idx].value-found) == len(unknown) { // every unknown is a mine
+				for _, u := range unknown {
+					known[u] = 2
+				}
+				changed = true
+			}
+		}
+	}
+	for idx := 0; idx < len(known); idx++ {
+		c := [2]uint16{uint16(idx % w), uint16(idx / w)}
+		switch known[idx] {
+		case 1:
+			safe = append(safe, c)
+		case 2:
+			mines = append(mines, c)
+		}
+	}
+	return safe, mines
+}
+
+// Auto makes a move that is guaranteed to be safe, returning the tile clicked
+func (g *Game) Auto() (x, y uint16, err error) {
+	if !g.endedAt.IsZero() {
+		return 0, 0, errors.New("Game is not active")
+	}
+	// the first click on a generated board is always safe, as is the center
+	// of a seeded one, so open in the center
+	if !g.placed || (0 != g.options.Seed && 0 == g.Revealed()) {
+		x = g.width / 2
+		y = g.height / 2
+		return x, y, g.ClickTile(x, y, false)
+	}
+	tiles := g.tiles
+	safe, _ := g.Solve()
+	for _, c := range safe {
+		// leave tiles the player has flagged alone
+		if tiles[g.index(c[0], c[1])].flagged {
+			continue
+		}
+		return c[0], c[1], g.ClickTile(c[0], c[1], false)
+	}
+	return 0, 0, errors.New("no safe move available")
+}
+
+// AutoFlag flags every tile that is provably a mine, returning the tiles
+// newly flagged
+func (g *Game) AutoFlag() (flagged [][2]uint16, err error) {
+	if !g.endedAt.IsZero() {
+		return nil, errors.New("Game is not active")
+	}
+	// shared games taking turns need to know who is clicking
+	if g.options.TakeTurns && 0 < len(g.members) {
+		return nil, errors.New("player token required")
+	}
+	// flagging every mine is one action, however many tiles it takes
+	if err = g.paced(); err != nil {
+		return nil, err
+	}
+	_, mines := g.Solve()
+	for _, c := range mines {
+		t := g.tiles[g.index(c[0], c[1])]
+		if t.flagged {
+			continue
+		}
+		// a question mark has to be cleared before it can be flagged
+		if t.question {
+			if err = g.click(context.Background(), c[0], c[1], true); err != nil {
+				return flagged, err
+			}
+		}
+		if err = g.click(context.Background(), c[0], c[1], true); err != nil {
+			return flagged, err
+		}
+		flagged = append(flagged, c)
+	}
+	return flagged, nil
+}
+
+// BoardValue computes the 3BV of the board: the number of openings plus every
+// numbered safe tile that does not border an opening
+func (g *Game) BoardValue() int {
+	if !g.placed {
+		return 0
+	}
+	w := int(g.width)
+	tiles := g.tiles
+	marked := make([]bool, len(tiles))
+	var total int
+	// flood each unmarked opening, marking its numbered border too
+	for idx := 0; idx < len(tiles); idx++ {
+		if marked[idx] || 0 != tiles[idx].value {
+			continue
+		}
+		total++
+		marked[idx] = true
+		stack := []int{idx}
+		for 0 < len(stack) {
+			cur := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			tileX := cur % w
+			tileY := cur / w
+			for _, n := range g.neighbors(uint16(tileX), uint16(tileY)) {
+				idx2 := g.index(n[0], n[1])
+				if marked[idx2] {
+					continue
+				}
+				marked[idx2] = true
+				if 0 == tiles[idx2].value {
+					stack = append(stack, idx2)
+				}
+			}
+		}
+	}
+	// every remaining safe tile takes a click of its own
+	for idx := 0; idx < len(tiles); idx++ {
+		if !marked[idx] && 9 != tiles[idx].value {
+			total++
+		}
+	}
+	return total
+}
+
+// NoGuess reports if the board can be cleared from an opening click at x,y
+// using only deduction, without disturbing the game itself
+func (g *Game) NoGuess(x, y uint16) bool {
+	if !g.placed || g.width <= x || g.height <= y {
+		return false
+	}
+	// play a fresh copy of the board, keeping only the values
+	probe := &Game{
+		options: g.options,
+		width:   g.width,
+		height:  g.height,
+		mines:   g.mines,
+		tiles:   make([]tile, len(g.tiles)),
+		placed:  true,
+		history: map[int]*turn{0: {}},
+	}
+	for i := range g.tiles {
+		probe.tiles[i].value = g.tiles[i].value
+	}
+	// the probe has to see the whole reveal at once
+	probe.options.CascadeLimit = 0
+	if 9 == probe.tiles[probe.index(x, y)].value {
+		return false
+	}
+	probe.revealTile(x, y)
+	for {
+		safe, _ := probe.Solve()
+		if 0 == len(safe) {
+			break
+		}
+		probe.reveal(safe)
+	}
+	return probe.cleared()
+}
@@ -0,0 +1,139 @@
+package mines
+
+import (
+	"testing"
+)
+
+func TestAutoNeverLoses(t *testing.T) {
+	for run := 0; run < 50; run++ {
+		g, err := NewGame(16, 16, 40)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the first move opens the center
+		x, y, err := g.Auto()
+		if err != nil || 8 != x || 8 != y {
+			t.Fatalf("run %d: first auto move %d,%d: %v", run, x, y, err)
+		}
+		for "active" == g.Status() {
+			if x, y, err = g.Auto(); err != nil {
+				break
+			}
+			if tile := g.tiles[g.index(x, y)]; !tile.clicked || 9 == tile.value {
+				t.Fatalf("run %d: auto move %d,%d left %+v", run, x, y, tile)
+			}
+		}
+		if "lost" == g.Status() {
+			t.Fatalf("run %d: auto move lost the game", run)
+		}
+	}
+}
+
+func TestAutoWithoutSafeMove(t *testing.T) {
+	g, err := NewGameFromLayout(5, 5, [][2]uint16{{0, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// nothing is revealed on a laid out board, so nothing can be deduced
+	if _, _, err = g.Auto(); nil == err {
+		t.Fatal("auto move guessed on an unopened board")
+	}
+	g.End(false)
+	if _, _, err = g.Auto(); nil == err {
+		t.Fatal("auto move on an ended game")
+	}
+}
+
+func TestBoardValue(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		w, h  uint16
+		mines [][2]uint16
+		want  int
+	}{
+		// every tile borders the mine, so each takes a click
+		{"numbers only", 3, 3, [][2]uint16{{1, 1}}, 8},
+		// one opening reaches every number
+		{"one opening", 5, 5, [][2]uint16{{0, 0}}, 1},
+		// the opening reaches one number, the other is cut off by the mine
+		{"opening and island", 4, 1, [][2]uint16{{1, 0}}, 2},
+		// two openings either side of a wall of mines
+		{"two openings", 5, 2, [][2]uint16{{2, 0}, {2, 1}}, 2},
+	} {
+		g, err := NewGameFromLayout(c.w, c.h, c.mines)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := g.BoardValue(); c.want != got {
+			t.Errorf("%s: 3BV %d, want %d", c.name, got, c.want)
+		}
+	}
+	g, err := NewGame(9, 9, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if 0 != g.BoardValue() {
+		t.Fatal("board without mines laid out has a 3BV")
+	}
+}
+
+func TestBoardValueOnlyOnceEnded(t *testing.T) {
+	g, err := NewGameFromLayout(4, 1, [][2]uint16{{1, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.ClickTile(3, 0, false)
+	if _, ok := stateOf(t, g, View{})["3bv"]; ok {
+		t.Fatal("active game shows its 3BV")
+	}
+	g.ClickTile(0, 0, false)
+	if bv := stateOf(t, g, View{})["3bv"]; 2.0 != bv {
+		t.Fatalf("won game shows 3BV %v, want 2", bv)
+	}
+}
+
+func TestAutoFlag(t *testing.T) {
+	wall := [][2]uint16{{0, 2}, {1, 2}, {2, 2}, {3, 2}, {4, 2}}
+	g, err := NewGameFromLayout(5, 5, wall)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// nothing can be deduced before the board is opened
+	if flagged, err := g.AutoFlag(); err != nil || 0 != len(flagged) || 0 != len(g.history) {
+		t.Fatalf("auto flag on an unopened board flagged %v: %v", flagged, err)
+	}
+	// opening the top rows shows the whole wall of mines
+	if err = g.ClickTile(0, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	// a question mark is cleared on the way to a flag
+	g.ClickTile(2, 2, true)
+	g.ClickTile(2, 2, true)
+	if !g.tiles[g.index(2, 2)].question {
+		t.Fatal("no question mark to clear")
+	}
+	flagged, err := g.AutoFlag()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wall) != len(flagged) || len(wall) != int(g.flags) {
+		t.Fatalf("auto flag flagged %v", flagged)
+	}
+	for _, c := range wall {
+		if !g.tiles[g.index(c[0], c[1])].flagged {
+			t.Fatalf("mine at %d,%d left unflagged", c[0], c[1])
+		}
+	}
+	if "active" != g.Status() {
+		t.Fatalf("auto flag ended the game: %s", g.Status())
+	}
+	// once every provable mine is flagged there is nothing left to do
+	turns := len(g.history)
+	if flagged, err = g.AutoFlag(); err != nil || 0 != len(flagged) || turns != len(g.history) {
+		t.Fatalf("second auto flag flagged %v: %v", flagged, err)
+	}
+	g.End(false)
+	if _, err = g.AutoFlag(); nil == err {
+		t.Fatal("auto flag on an ended game")
+	}
+}
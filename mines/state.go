@@ -0,0 +1,198 @@
+package mines
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// tile bits in an exported state, a byte per tile
+const (
+	stateMine = 1 << iota
+	stateClicked
+	stateFlagged
+	stateQuestion
+)
+
+// savedGame is everything needed to continue an active game elsewhere. It
+// holds the mines, so it only ever leaves the server sealed.
+type savedGame struct {
+	Width      uint16      `json:"w"`
+	Height     uint16      `json:"h"`
+	Mines      uint16      `json:"m"`
+	Options    savedOpts   `json:"o"`
+	Seed       int64       `json:"seed,omitempty"`
+	Placed     bool        `json:"placed,omitempty"`
+	Played     int64       `json:"played"`         // milliseconds played
+	LastTurnAt int64       `json:"last,omitempty"` // Unix milliseconds
+	Player     string      `json:"player,omitempty"`
+	Members    [][2]string `json:"members,omitempty"` // token and name
+	Next       int         `json:"next,omitempty"`
+	Tiles      []byte      `json:"tiles,omitempty"`
+	Frontier   [][2]uint16 `json:"frontier,omitempty"`
+}
+
+// savedOpts are the options of a saved game, all but the clock
+type savedOpts struct {
+	QuestionMarks bool        `json:"q,omitempty"`
+	Secure        bool        `json:"secure,omitempty"`
+	Wrap          bool        `json:"wrap,omitempty"`
+	Topology      Topology    `json:"topology,omitempty"`
+	TakeTurns     bool        `json:"turns,omitempty"`
+	Seed          int64       `json:"seed,omitempty"`
+	Protected     [][2]uint16 `json:"protected,omitempty"`
+	ZeroOpening   bool        `json:"zero,omitempty"`
+	AutoChord     bool        `json:"chord,omitempty"`
+	CascadeLimit  int         `json:"cascade,omitempty"`
+	MinInterval   int64       `json:"min_interval,omitempty"` // nanoseconds
+	NoClusters    bool        `json:"nocluster,omitempty"`
+}
+
+// exportState encodes an active game, mines and all, as JSON that
+// importState can continue. The output reveals the board, so callers must
+// seal it before it leaves the server.
+func (g *Game) exportState() ([]byte, error) {
+	if !g.endedAt.IsZero() {
+		return nil, errors.New("Game is not active")
+	}
+	o := g.options
+	s := savedGame{
+		Width:  g.width,
+		Height: g.height,
+		Mines:  g.mines,
+		Options: savedOpts{
+			QuestionMarks: o.QuestionMarks,
+			Secure:        o.Secure,
+			Wrap:          o.Wrap,
+			Topology:      o.Topology,
+			TakeTurns:     o.TakeTurns,
+			Seed:          o.Seed,
+			Protected:     o.Protected,
+			ZeroOpening:   o.ZeroOpening,
+			AutoChord:     o.AutoChord,
+			CascadeLimit:  o.CascadeLimit,
+			MinInterval:   int64(o.MinInterval),
+			NoClusters:    o.NoClusters,
+		},
+		Seed:     g.seed,
+		Placed:   g.placed,
+		Played:   int64(g.Duration() / time.Millisecond),
+		Player:   g.player,
+		Next:     g.next,
+		Frontier: g.frontier,
+	}
+	if last := g.lastTurnAt(); !last.IsZero() {
+		s.LastTurnAt = last.UnixMilli()
+	}
+	for _, m := range g.members {
+		s.Members = append(s.Members, [2]string{m.token, m.name})
+	}
+	if nil != g.tiles {
+		s.Tiles = make([]byte, len(g.tiles))
+		for i, t := range g.tiles {
+			if 9 == t.value {
+				s.Tiles[i] |= stateMine
+			}
+			if t.clicked {
+				s.Tiles[i] |= stateClicked
+			}
+			if t.flagged {
+				s.Tiles[i] |= stateFlagged
+			}
+			if t.question {
+				s.Tiles[i] |= stateQuestion
+			}
+		}
+	}
+	return json.Marshal(s)
+}
+
+// importState continues a game from exportState output, as a new game with
+// no history, its clock already running for the time played, and the same
+// options, seed and players as the original
+func importState(b []byte) (*Game, error) {
+	var s savedGame
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, errors.New("invalid state code")
+	}
+	o := Options{
+		QuestionMarks: s.Options.QuestionMarks,
+		Secure:        s.Options.Secure,
+		Wrap:          s.Options.Wrap,
+		Topology:      s.Options.Topology,
+		TakeTurns:     s.Options.TakeTurns,
+		Seed:          s.Options.Seed,
+		Protected:     s.Options.Protected,
+		ZeroOpening:   s.Options.ZeroOpening,
+		AutoChord:     s.Options.AutoChord,
+		CascadeLimit:  s.Options.CascadeLimit,
+		MinInterval:   time.Duration(s.Options.MinInterval),
+		NoClusters:    s.Options.NoClusters,
+	}
+	g, err := NewGameWithOptions(s.Width, s.Height, s.Mines, o)
+	if err != nil {
+		return nil, err
+	}
+	// an unplaced board is still dealt from the original seed
+	if !o.Secure {
+		g.seed = s.Seed
+		g.rng = rand.New(rand.NewSource(g.seed))
+	}
+	g.tiles = nil
+	g.placed = s.Placed
+	if 0 < len(s.Tiles) {
+		if len(s.Tiles) != int(g.width)*int(g.height) {
+			return nil, errors.New("invalid state code")
+		}
+		g.tiles = make([]tile, len(s.Tiles))
+		var mines int
+		for i, v := range s.Tiles {
+			if 0 != v&stateMine {
+				g.tiles[i].value = 9
+				mines++
+			}
+			g.tiles[i].clicked = 0 != v&stateClicked
+			g.tiles[i].flagged = 0 != v&stateFlagged
+			g.tiles[i].question = 0 != v&stateQuestion
+			if g.tiles[i].flagged {
+				g.flags++
+			}
+			if g.tiles[i].clicked && (9 == g.tiles[i].value || !g.placed) {
+				return nil, errors.New("Game is not active")
+			}
+		}
+		if (g.placed && int(g.mines) != mines) || (!g.placed && 0 != mines) {
+			return nil, errors.New("invalid state code")
+		}
+		if g.placed {
+			g.countMines(g.tiles)
+		}
+	} else if g.placed {
+		return nil, errors.New("invalid state code")
+	}
+	if g.cleared() {
+		return nil, errors.New("Game is not active")
+	}
+	for _, c := range s.Frontier {
+		if g.width <= c[0] || g.height <= c[1] {
+			return nil, errors.New("invalid state code")
+		}
+	}
+	g.frontier = s.Frontier
+	// the client may have held onto earlier copies
+	g.ranked = false
+	g.player = s.Player
+	for _, m := range s.Members {
+		g.members = append(g.members, member{token: m[0], name: m[1]})
+	}
+	if 0 < len(g.members) {
+		g.next = s.Next % len(g.members)
+	}
+	if 0 != s.LastTurnAt {
+		g.importedTurnAt = time.UnixMilli(s.LastTurnAt)
+	}
+	played := time.Duration(s.Played) * time.Millisecond
+	g.startedAt = g.startedAt.Add(-played)
+	return g, nil
+}
@@ -0,0 +1,108 @@
+package mines
+
+import (
+	"fmt"
+)
+
+// SymbolSet names each state a tile can be shown in
+type SymbolSet struct {
+	Mine      string    `json:"mine"`       // unflagged mine on a lost board
+	WrongFlag string    `json:"wrong_flag"` // flag on a safe tile on a lost board
+	Flag      string    `json:"flag"`       // flag placed by the player
+	AutoMine  string    `json:"auto_mine"`  // unflagged mine on a won board
+	Question  string    `json:"question"`   // uncertain tile
+	Hidden    string    `json:"hidden"`     // unchecked tile
+	Numbers   [9]string `json:"numbers"`    // open tiles, by neighboring mines
+}
+
+// DefaultSymbols used by the JSON and ASCII boards
+var DefaultSymbols = SymbolSet{
+	Mine:      "9",
+	WrongFlag: "X",
+	Flag:      "!",
+	AutoMine:  "*",
+	Question:  "Q",
+	Hidden:    "?",
+	// leave empty open tiles with no label
+	Numbers: [9]string{"", "1", "2", "3", "4", "5", "6", "7", "8"},
+}
+
+// MaxSymbolLen of any one symbol, in bytes, so a board can't be blown up by
+// its symbols
+var MaxSymbolLen = 8
+
+// Check that no symbol is longer than MaxSymbolLen
+func (s SymbolSet) Check() error {
+	named := [][2]string{
+		{"mine", s.Mine},
+		{"wrong_flag", s.WrongFlag},
+		{"flag", s.Flag},
+		{"auto_mine", s.AutoMine},
+		{"question", s.Question},
+		{"hidden", s.Hidden},
+	}
+	for i, n := range s.Numbers {
+		named = append(named, [2]string{fmt.Sprintf("numbers[%d]", i), n})
+	}
+	for _, n := range named {
+		if MaxSymbolLen < len(n[1]) {
+			return fmt.Errorf("symbol %s is longer than %d bytes", n[0], MaxSymbolLen)
+		}
+	}
+	return nil
+}
+
+// symbol for a tile in a game that may be won or lost
+func (s SymbolSet) symbol(t tile, won, lost bool) string {
+	isMine := 9 == t.value
+	if (lost || t.clicked) && isMine && !t.flagged {
+		// expose non-flagged mines if the game is over and lost, and the
+		// one that went off however the board is shown
+		return s.Mine
+	} else if lost && !isMine && t.flagged {
+		// mark incorrect flags if the game is over and lost
+		return s.WrongFlag
+	} else if t.flagged {
+		// mark flags
+		return s.Flag
+	} else if won && isMine {
+		// mark the mines the player left unflagged on a win
+		return s.AutoMine
+	} else if t.question {
+		// mark uncertain tiles
+		return s.Question
+	} else if !t.clicked {
+		// mark unchecked tiles
+		return s.Hidden
+	}
+	// label values 0-8
+	return s.Numbers[t.value]
+}
+
+// Cell is a tile as a state and, once open, its neighboring mines, for
+// clients that would rather not parse symbols
+type Cell struct {
+	S string `json:"s"` // hidden, question, flag, auto_mine, wrong_flag, mine or open
+	N *uint8 `json:"n,omitempty"`
+}
+
+// cell for a tile in a game that may be won or lost, in the same order of
+// precedence as symbol
+func cell(t tile, won, lost bool) Cell {
+	isMine := 9 == t.value
+	if (lost || t.clicked) && isMine && !t.flagged {
+		return Cell{S: "mine"}
+	} else if lost && !isMine && t.flagged {
+		return Cell{S: "wrong_flag"}
+	} else if t.flagged {
+		return Cell{S: "flag"}
+	} else if won && isMine {
+		return Cell{S: "auto_mine"}
+	} else if t.question {
+		return Cell{S: "question"}
+	} else if !t.clicked {
+		return Cell{S: "hidden"}
+	}
+	n := t.value
+	return Cell{S: "open", N: &n}
+}
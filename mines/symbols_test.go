@@ -0,0 +1,139 @@
+package mines
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// tilesOf the board as seen through v, as one string
+func tilesOf(t *testing.T, g *Game, v View) string {
+	t.Helper()
+	var s []string
+	for _, tile := range stateOf(t, g, v)["tiles"].([]interface{}) {
+		s = append(s, tile.(string))
+	}
+	return strings.Join(s, ",")
+}
+
+func TestSymbolSets(t *testing.T) {
+	g, err := NewGameFromLayout(3, 3, [][2]uint16{{0, 0}, {2, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.ClickTile(1, 2, false)
+	g.ClickTile(0, 0, true)
+	g.ClickTile(1, 0, true)
+	g.ClickTile(1, 0, true)
+	if got := tilesOf(t, g, View{}); "!,Q,?,1,2,1,,," != got {
+		t.Fatalf("default symbols %s", got)
+	}
+	emoji := SymbolSet{
+		Mine: "💣", WrongFlag: "❌", Flag: "🚩", AutoMine: "💣", Question: "❓", Hidden: "⬜",
+		Numbers: [9]string{"0", "1", "2", "3", "4", "5", "6", "7", "8"},
+	}
+	if got := tilesOf(t, g, View{Symbols: &emoji}); "🚩,❓,⬜,1,2,1,0,0,0" != got {
+		t.Fatalf("emoji symbols %s", got)
+	}
+	// a loss shows mines and wrong flags in the chosen symbols too
+	g.ClickTile(2, 0, false)
+	if got := tilesOf(t, g, View{Symbols: &emoji}); "🚩,❓,💣,1,2,1,0,0,0" != got {
+		t.Fatalf("lost emoji symbols %s", got)
+	}
+}
+
+func TestSymbolCheck(t *testing.T) {
+	if err := DefaultSymbols.Check(); err != nil {
+		t.Fatal(err)
+	}
+	long := DefaultSymbols
+	long.Numbers[3] = strings.Repeat("3", MaxSymbolLen+1)
+	if err := long.Check(); nil == err || !strings.Contains(err.Error(), "numbers[3]") {
+		t.Fatalf("long number symbol: %v", err)
+	}
+	long = DefaultSymbols
+	long.Hidden = strings.Repeat("x", MaxSymbolLen+1)
+	if err := long.Check(); nil == err || !strings.Contains(err.Error(), "hidden") {
+		t.Fatalf("long hidden symbol: %v", err)
+	}
+}
+
+func TestNumericMatchesSymbols(t *testing.T) {
+	g, err := NewGameFromLayout(3, 3, [][2]uint16{{0, 0}, {2, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.ClickTile(1, 2, false)
+	g.ClickTile(0, 0, true)
+	g.ClickTile(1, 0, true)
+	g.ClickTile(1, 0, true)
+	// misflag a safe tile, then lose, to show every state
+	g.ClickTile(0, 1, true)
+	g.ClickTile(2, 0, false)
+	states := map[string]string{
+		"!": "flag", "Q": "question", "?": "hidden", "9": "mine", "X": "wrong_flag", "*": "auto_mine",
+	}
+	symbols := strings.Split(tilesOf(t, g, View{}), ",")
+	cells := stateOf(t, g, View{Numeric: true})["tiles"].([]interface{})
+	if len(symbols) != len(cells) {
+		t.Fatalf("%d symbols but %d cells", len(symbols), len(cells))
+	}
+	for i, c := range cells {
+		c := c.(map[string]interface{})
+		want, ok := states[symbols[i]]
+		if !ok {
+			// only open tiles carry their number, even a zero
+			want = "open"
+			n, has := c["n"].(float64)
+			label := strconv.Itoa(int(n))
+			if 0 == n {
+				label = ""
+			}
+			if !has || label != symbols[i] {
+				t.Fatalf("tile %d shows %q but counts %v", i, symbols[i], c["n"])
+			}
+		} else if _, ok := c["n"]; ok {
+			t.Fatalf("%s tile %d has a count", want, i)
+		}
+		if want != c["s"] {
+			t.Fatalf("tile %d shows %q but is %v", i, symbols[i], c["s"])
+		}
+	}
+}
+
+func TestWonMinesAreMarked(t *testing.T) {
+	g, err := NewGameFromLayout(3, 3, [][2]uint16{{0, 0}, {2, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// one mine flagged, the other never found
+	g.ClickTile(0, 0, true)
+	g.ClickTile(1, 2, false)
+	g.ClickTile(1, 0, false)
+	if "won" != g.Status() {
+		t.Fatalf("game is %s, want won", g.Status())
+	}
+	if got := tilesOf(t, g, View{}); "!,2,*,1,2,1,,," != got {
+		t.Fatalf("won board %s", got)
+	}
+	legacy := View{Legacy: true}
+	if got := tilesOf(t, g, legacy); "!,2,!,1,2,1,,," != got {
+		t.Fatalf("legacy won board %s", got)
+	}
+	// single tiles and deltas tell them apart the same way
+	if tile, _ := g.TileView(2, 0, View{}); DefaultSymbols.AutoMine != tile.Symbol {
+		t.Fatalf("unflagged mine is %q", tile.Symbol)
+	}
+	if tile, _ := g.TileView(2, 0, legacy); DefaultSymbols.Flag != tile.Symbol {
+		t.Fatalf("legacy unflagged mine is %q", tile.Symbol)
+	}
+	shown := false
+	for _, c := range g.TurnDeltaView(legacy) {
+		if 2 == c.X && 0 == c.Y {
+			shown = DefaultSymbols.Flag == c.Val
+		}
+	}
+	if !shown {
+		t.Fatalf("legacy delta %v doesn't flag the mine", g.TurnDeltaView(legacy))
+	}
+}
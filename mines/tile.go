@@ -0,0 +1,78 @@
+package mines
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// TileState is what a player can see of a single tile
+type TileState struct {
+	X        uint16 `json:"x"`
+	Y        uint16 `json:"y"`
+	Symbol   string `json:"symbol"`
+	Clicked  bool   `json:"clicked"`
+	Flagged  bool   `json:"flagged"`
+	Question bool   `json:"question"`
+	Value    *uint8 `json:"value,omitempty"` // only once clicked
+}
+
+// Tile at x,y on the latest turn, without giving away hidden mines
+func (g *Game) Tile(x, y uint16) (TileState, error) {
+	return g.TileView(x, y, View{})
+}
+
+// TileView at x,y on the latest turn, with its symbol as viewed
+func (g *Game) TileView(x, y uint16, v View) (TileState, error) {
+	if g.width <= x || g.height <= y {
+		return TileState{}, errors.New("tile is off the board")
+	}
+	s := TileState{X: x, Y: y, Symbol: v.symbols().Hidden}
+	// nothing is known before the board is generated
+	if nil == g.tiles {
+		return s, nil
+	}
+	t := g.tiles[g.index(x, y)]
+	s.Symbol = g.symbolView(t, v)
+	s.Clicked = t.clicked
+	s.Flagged = t.flagged
+	s.Question = t.question
+	if t.clicked {
+		v := t.value
+		s.Value = &v
+	}
+	return s, nil
+}
+
+// debugTile is the whole internal state of a tile
+type debugTile struct {
+	Value    uint8 `json:"value"`
+	Clicked  bool  `json:"clicked"`
+	Flagged  bool  `json:"flagged"`
+	Question bool  `json:"question"`
+}
+
+// DebugJSON writes the whole internal board, mines and all, to a JSON string.
+// Never show it to a player.
+func (g *Game) DebugJSON() (string, error) {
+	tiles := make([]debugTile, len(g.tiles))
+	mines := make([][2]uint16, 0, g.mines)
+	for i, t := range g.tiles {
+		tiles[i] = debugTile{Value: t.value, Clicked: t.clicked, Flagged: t.flagged, Question: t.question}
+		if 9 == t.value {
+			mines = append(mines, [2]uint16{uint16(i % int(g.width)), uint16(i / int(g.width))})
+		}
+	}
+	obj := make(map[string]interface{})
+	obj["width"] = g.width
+	obj["height"] = g.height
+	obj["generated"] = g.placed
+	obj["seed"] = g.seed
+	obj["mines"] = mines
+	obj["tiles"] = tiles
+	obj["status"] = g.Status()
+	json, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	return string(json), nil
+}
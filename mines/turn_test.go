@@ -0,0 +1,203 @@
+package mines
+
+import (
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestTilesAtMatchesSnapshots(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	for run := 0; run < 50; run++ {
+		g, err := NewGameWithOptions(16, 16, 40, DefaultOptions())
+		if err != nil {
+			t.Fatal(err)
+		}
+		// full copies of the grid after every turn, as history used to keep
+		var snapshots [][]tile
+		for "active" == g.Status() && len(snapshots) < 200 {
+			g.ClickTile(uint16(r.Intn(16)), uint16(r.Intn(16)), 0 != r.Intn(3))
+			if len(snapshots) < len(g.history) {
+				snapshots = append(snapshots, append([]tile(nil), g.tiles...))
+			}
+		}
+		if len(snapshots) != len(g.history) {
+			t.Fatalf("run %d: %d snapshots of %d turns", run, len(snapshots), len(g.history))
+		}
+		for i := range snapshots {
+			if got := g.tilesAt(i); !reflect.DeepEqual(snapshots[i], got) {
+				t.Fatalf("run %d: turn %d rebuilt differently", run, i)
+			}
+		}
+	}
+}
+
+func BenchmarkLongGame(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		g, err := NewGameFromLayout(MaxWidth, MaxHeight, [][2]uint16{{0, 0}})
+		if err != nil {
+			b.Fatal(err)
+		}
+		// many small turns on a large board
+		for n := 0; n < 1000; n++ {
+			g.ClickTile(uint16(1+n%100), uint16(n/100), true)
+		}
+	}
+}
+
+func TestNoOpClicksTakeNoTurn(t *testing.T) {
+	g, err := NewGameFromLayout(4, 4, [][2]uint16{{0, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.ClickTile(1, 1, false)
+	turns := len(g.history)
+	// clicking an open number that isn't satisfied, or flagging it, does
+	// nothing
+	g.ClickTile(1, 1, false)
+	g.ClickTile(1, 1, true)
+	if turns != len(g.history) || 1 != g.Clicks() {
+		t.Fatalf("%d turns and %d clicks, want %d and 1", len(g.history), g.Clicks(), turns)
+	}
+	// clicking a flag does nothing either
+	g.ClickTile(0, 0, true)
+	g.ClickTile(0, 0, false)
+	if turns+1 != len(g.history) {
+		t.Fatalf("%d turns, want %d", len(g.history), turns+1)
+	}
+}
+
+func TestTurnByID(t *testing.T) {
+	g, err := NewGameFromLayout(5, 5, [][2]uint16{{0, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.ClickTile(0, 0, true)
+	g.ClickTile(4, 4, true)
+	byIndex, err := g.Turn("0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	byUUID, err := g.Turn(g.history[0].uid.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byIndex != byUUID {
+		t.Fatalf("turn by index %s but by uuid %s", byIndex, byUUID)
+	}
+	latest, _ := g.JSON()
+	if byIndex == latest {
+		t.Fatal("first turn shows the latest board")
+	}
+	for _, id := range []string{"2", "-1", "nonsense", uuid.NewString(), g.UUID().String()} {
+		if _, err = g.Turn(id); nil == err {
+			t.Fatalf("found turn %q", id)
+		}
+	}
+}
+
+func TestHistoricalTurnShowsOnlyItsOwnState(t *testing.T) {
+	g, err := NewGameFromLayout(7, 7, [][2]uint16{{0, 0}, {1, 1}, {5, 5}, {6, 6}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.ClickTile(3, 3, false)
+	g.ClickTile(1, 1, true)
+	g.ClickTile(5, 5, true)
+	g.ClickTile(0, 0, false)
+	if "lost" != g.Status() {
+		t.Fatalf("game is %s, want lost", g.Status())
+	}
+	turn := func(n string) map[string]interface{} {
+		js, err := g.Turn(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var obj map[string]interface{}
+		json.Unmarshal([]byte(js), &obj)
+		return obj
+	}
+	first := turn("0")
+	for _, key := range []string{"ended_at", "won", "3bv", "detonated", "mine_reveal_order", "flags_correct", "flags_wrong", "efficiency", "seed", "paused", "frontier"} {
+		if _, ok := first[key]; ok {
+			t.Errorf("turn 0 has %s", key)
+		}
+	}
+	if 0.0 != first["flags"] || 1.0 != first["clicks"] || 1.0 != first["turns"] {
+		t.Fatalf("turn 0 has %v flags, %v clicks, %v turns", first["flags"], first["clicks"], first["turns"])
+	}
+	if second := turn("1"); 1.0 != second["flags"] || 1.0 != second["clicks"] || 2.0 != second["turns"] {
+		t.Fatalf("turn 1 has %v flags, %v clicks, %v turns", second["flags"], second["clicks"], second["turns"])
+	}
+	last := turn("3")
+	if _, ok := last["detonated"]; !ok || 2.0 != last["flags"] || 2.0 != last["clicks"] || 4.0 != last["turns"] {
+		t.Fatalf("last turn is %v", last)
+	}
+}
+
+func TestTurnsMatchLiveBoards(t *testing.T) {
+	g, err := NewGameFromLayout(7, 7, [][2]uint16{{0, 0}, {1, 1}, {5, 5}, {6, 6}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var live []interface{}
+	for _, m := range []Move{{X: 3, Y: 3}, {X: 1, Y: 1, Flag: true}, {X: 5, Y: 5, Flag: true}, {X: 5, Y: 5, Flag: true}, {X: 6, Y: 6}} {
+		if err = g.ClickTile(m.X, m.Y, m.Flag); err != nil {
+			t.Fatal(err)
+		}
+		live = append(live, stateOf(t, g, View{})["tiles"])
+	}
+	if len(live) != len(g.history) {
+		t.Fatalf("%d moves took %d turns", len(live), len(g.history))
+	}
+	for i, want := range live {
+		js, err := g.Turn(strconv.Itoa(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var obj map[string]interface{}
+		if err = json.Unmarshal([]byte(js), &obj); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(want, obj["tiles"]) {
+			t.Fatalf("turn %d shows %v, but was %v", i, obj["tiles"], want)
+		}
+	}
+}
+
+func TestTurnCount(t *testing.T) {
+	g, err := NewGameFromLayout(5, 5, [][2]uint16{{0, 0}, {4, 4}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := stateOf(t, g, View{})["turns"]; 0.0 != n {
+		t.Fatalf("fresh game took %v turns", n)
+	}
+	want := 0.0
+	for _, m := range []Move{{X: 0, Y: 0, Flag: true}, {X: 4, Y: 4, Flag: true}, {X: 2, Y: 2}, {X: 2, Y: 2}} {
+		before := len(g.history)
+		g.ClickTile(m.X, m.Y, m.Flag)
+		// clicks that change nothing aren't turns
+		if before < len(g.history) {
+			want++
+		}
+		if n := stateOf(t, g, View{})["turns"]; want != n {
+			t.Fatalf("after %v took %v turns, want %v", m, n, want)
+		}
+	}
+	if 3.0 != want {
+		t.Fatalf("took %v turns, want 3", want)
+	}
+	// every turn counted can be replayed, and no more
+	if _, err = g.Turn(strconv.Itoa(int(want) - 1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = g.Turn(strconv.Itoa(int(want))); nil == err {
+		t.Fatal("replayed a turn past the count")
+	}
+}
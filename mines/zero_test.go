@@ -0,0 +1,50 @@
+package mines
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestZeroOpening(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for run := 0; run < 200; run++ {
+		o := Options{ZeroOpening: true, Wrap: 0 == run%2}
+		if 0 == run%3 {
+			o.Topology = Hex
+		}
+		g, err := NewGameWithOptions(16, 16, 40, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := g.Opening(); ok {
+			t.Fatal("unseeded board named an opening")
+		}
+		x, y := uint16(r.Intn(16)), uint16(r.Intn(16))
+		g.ClickTile(x, y, false)
+		if v := g.tiles[g.index(x, y)].value; 0 != v {
+			t.Fatalf("first click at %d,%d is %d, want 0", x, y, v)
+		}
+	}
+}
+
+func TestZeroOpeningSeeded(t *testing.T) {
+	for seed := int64(1); seed < 100; seed++ {
+		g, err := NewGameWithOptions(16, 16, 40, Options{ZeroOpening: true, Seed: seed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, ok := g.Opening()
+		if !ok || [2]uint16{8, 8} != c {
+			t.Fatalf("seeded board opens at %v, %v", c, ok)
+		}
+		if v := g.tiles[g.index(c[0], c[1])].value; 0 != v {
+			t.Fatalf("seed %d opening is %d, want 0", seed, v)
+		}
+	}
+}
+
+func TestZeroOpeningTooManyMines(t *testing.T) {
+	if _, err := NewGameWithOptions(4, 4, 8, Options{ZeroOpening: true}); nil == err {
+		t.Fatal("made a zero opening with no room around the first click")
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// trustedProxies whose X-Forwarded-For is believed, none unless configured
+var trustedProxies []*net.IPNet
+
+func init() {
+	trustedProxies = parseProxies(os.Getenv("MINES_SERVER_TRUSTED_PROXIES"))
+}
+
+// parseProxies from a comma separated list of addresses and CIDR ranges,
+// skipping any that don't parse
+func parseProxies(list string) (nets []*net.IPNet) {
+	for _, p := range strings.Split(list, ",") {
+		p = strings.TrimSpace(p)
+		if "" == p {
+			continue
+		}
+		// a bare address is a range of one
+		cidr := p
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); nil != ip && nil != ip.To4() {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		if _, n, err := net.ParseCIDR(cidr); err == nil {
+			nets = append(nets, n)
+			continue
+		}
+		log.Printf("invalid trusted proxy: %s", p)
+	}
+	return nets
+}
+
+// trusted reports if an address belongs to a configured proxy
+func trusted(addr string) bool {
+	ip := net.ParseIP(addr)
+	if nil == ip {
+		return false
+	}
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// bucket of tokens for a single client
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter is a token bucket per client IP
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens added per second
+	burst   float64 // most tokens a bucket can hold
+	buckets map[string]*bucket
+}
+
+// newRateLimiter allowing rate requests per second, in bursts of up to burst
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if 1 > burst {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// allow a request from a client, or report how long until it would be
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	// refill for the time since the last request
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if 1 <= b.tokens {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// cleanup forgets clients that have been idle long enough to refill
+func (l *rateLimiter) cleanup() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	idle := time.Duration(l.burst / l.rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if idle < time.Since(b.last) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// run cleanup every interval, forever
+func (l *rateLimiter) clean(interval time.Duration) {
+	for range time.Tick(interval) {
+		l.cleanup()
+	}
+}
+
+// withRateLimit rejects clients that are over the limit
+func (l *rateLimiter) withRateLimit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ok, retry := l.allow(clientIP(r))
+		if !ok {
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", int(math.Ceil(retry.Seconds()))))
+			jsonErrorString(w, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// clientIP of the request. X-Forwarded-For is only read when the request
+// comes from a trusted proxy, and then from the right, skipping the trusted
+// proxies that added to it, since anything further left is the client's say.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !trusted(host) {
+		return host
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if "" == hop {
+			continue
+		}
+		if !trusted(hop) {
+			return hop
+		}
+		host = hop
+	}
+	return host
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	defer func(saved []*net.IPNet) { trustedProxies = saved }(trustedProxies)
+	trustedProxies = parseProxies("10.0.0.1, 192.168.0.0/16, nonsense, ::1")
+	for _, c := range []struct {
+		remote, forwarded, want string
+	}{
+		// anyone else's header is ignored
+		{"203.0.113.9:5000", "198.51.100.1", "203.0.113.9"},
+		{"203.0.113.9:5000", "", "203.0.113.9"},
+		{"10.0.0.1:5000", "", "10.0.0.1"},
+		{"10.0.0.1:5000", "198.51.100.1", "198.51.100.1"},
+		{"[::1]:5000", "198.51.100.1", "198.51.100.1"},
+		// entries left of the first untrusted hop are the client's say
+		{"10.0.0.1:5000", "1.2.3.4, 198.51.100.1, 192.168.4.4", "198.51.100.1"},
+		{"10.0.0.1:5000", "192.168.1.1, 192.168.4.4", "192.168.1.1"},
+	} {
+		r := httptest.NewRequest("GET", "/games/", nil)
+		r.RemoteAddr = c.remote
+		if "" != c.forwarded {
+			r.Header.Set("X-Forwarded-For", c.forwarded)
+		}
+		if got := clientIP(r); c.want != got {
+			t.Errorf("%s forwarding %q got %s, want %s", c.remote, c.forwarded, got, c.want)
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	l := newRateLimiter(0.001, 2)
+	h := l.withRateLimit(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	send := func(remote, forwarded string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/games/", nil)
+		r.RemoteAddr = remote
+		r.Header.Set("X-Forwarded-For", forwarded)
+		w := httptest.NewRecorder()
+		h(w, r)
+		return w
+	}
+	for i := 0; i < 2; i++ {
+		if w := send("203.0.113.9:5000", "1.1.1.1"); http.StatusNoContent != w.Code {
+			t.Fatalf("request %d got %d", i, w.Code)
+		}
+	}
+	// a new forwarded address doesn't buy an untrusted client a new bucket
+	w := send("203.0.113.9:5000", "2.2.2.2")
+	if http.StatusTooManyRequests != w.Code || "" == w.Header().Get("Retry-After") {
+		t.Fatalf("over the limit got %d", w.Code)
+	}
+	if w = send("203.0.113.10:5000", ""); http.StatusNoContent != w.Code {
+		t.Fatalf("another client got %d", w.Code)
+	}
+}
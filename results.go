@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+
+	"github.com/jeffchannell/mines-server/mines"
+)
+
+// logResults of ended games, one JSON line each, for offline analysis
+var logResults = "1" == os.Getenv("MINES_SERVER_LOG_RESULTS")
+
+func init() {
+	mines.OnEnd(logResult)
+}
+
+// logResult of an ended game, if enabled
+func logResult(g *mines.Game) {
+	if !logResults {
+		return
+	}
+	obj := map[string]interface{}{
+		"uuid":        g.UUID(),
+		"result":      g.Status(),
+		"duration_ms": g.Duration() / time.Millisecond,
+		"3bv":         g.BoardValue(),
+		"width":       g.Width(),
+		"height":      g.Height(),
+		"mines":       g.Mines(),
+		"difficulty":  g.Difficulty(),
+	}
+	// secure boards have no seed to replay
+	if seed := g.Seed(); 0 != seed {
+		obj["seed"] = seed
+	}
+	b, err := json.Marshal(obj)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	log.Printf("game result %s", b)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/jeffchannell/mines-server/mines"
+)
+
+// resultLog of ended games while fn runs, with logging of results set to on
+func resultLog(t *testing.T, on bool, fn func()) string {
+	t.Helper()
+	var buf bytes.Buffer
+	was, out, flags := logResults, log.Writer(), log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	logResults = on
+	defer func() {
+		logResults = was
+		log.SetOutput(out)
+		log.SetFlags(flags)
+	}()
+	fn()
+	return buf.String()
+}
+
+func TestLogResult(t *testing.T) {
+	g, err := mines.NewGameWithOptions(9, 9, 10, mines.Options{Seed: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := resultLog(t, true, func() { win(t, g) })
+	line := strings.TrimPrefix(strings.TrimSpace(out), "game result ")
+	var obj map[string]interface{}
+	if err = json.Unmarshal([]byte(line), &obj); err != nil {
+		t.Fatalf("logged %q: %v", out, err)
+	}
+	if g.UUID().String() != obj["uuid"] || "won" != obj["result"] || "beginner" != obj["difficulty"] || 6.0 != obj["seed"] {
+		t.Fatalf("logged %v", obj)
+	}
+	for _, key := range []string{"duration_ms", "3bv", "width", "height", "mines"} {
+		if _, ok := obj[key]; !ok {
+			t.Fatalf("logged result has no %s: %v", key, obj)
+		}
+	}
+}
+
+func TestLogResultOff(t *testing.T) {
+	g, err := mines.NewGame(9, 9, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := resultLog(t, false, func() { g.End(false) }); "" != out {
+		t.Fatalf("logged %q with results off", out)
+	}
+}
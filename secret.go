@@ -0,0 +1,20 @@
+package main
+
+import (
+	"crypto/rand"
+	"os"
+)
+
+// secret sealing game state held by clients. Without MINES_SERVER_SECRET a
+// random one is made. Either way, blobs can only be resumed from the process
+// that issued them, as it remembers which are still unused.
+var secret = []byte(os.Getenv("MINES_SERVER_SECRET"))
+
+func init() {
+	if 0 == len(secret) {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jeffchannell/mines-server/mines"
+)
+
+// serverStats totals every game since the server started
+type serverStats struct {
+	mu           sync.Mutex
+	created      int
+	won          int
+	lost         int
+	winTime      time.Duration  // total duration of won games
+	difficulties map[string]int // games created, by difficulty
+}
+
+var stats = &serverStats{difficulties: make(map[string]int)}
+
+func init() {
+	mines.OnEnd(stats.ended)
+}
+
+// add a newly created game
+func (s *serverStats) add(g *mines.Game) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.created++
+	s.difficulties[g.Difficulty()]++
+}
+
+// ended game, counted by outcome if it was created here. Resumed games and
+// the boards made by previews and health checks are never stored, so don't
+// count.
+func (s *serverStats) ended(g *mines.Game) {
+	if !isStored(g.UUID()) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if "won" == g.Status() {
+		s.won++
+		s.winTime += g.Duration()
+	} else {
+		s.lost++
+	}
+}
+
+// snapshot of the totals, ready to be marshaled
+func (s *serverStats) snapshot() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj := make(map[string]interface{})
+	obj["created"] = s.created
+	obj["won"] = s.won
+	obj["lost"] = s.lost
+	var avg time.Duration
+	if 0 < s.won {
+		avg = s.winTime / time.Duration(s.won)
+	}
+	obj["average_win_ms"] = avg / time.Millisecond
+	// ties go to the first name, so the answer doesn't flap
+	names := make([]string, 0, len(s.difficulties))
+	for name := range s.difficulties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var top string
+	for _, name := range names {
+		if "" == top || s.difficulties[top] < s.difficulties[name] {
+			top = name
+		}
+	}
+	if "" != top {
+		obj["top_difficulty"] = top
+	}
+	return obj
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/jeffchannell/mines-server/mines"
+)
+
+func TestStatsSnapshot(t *testing.T) {
+	s := &serverStats{difficulties: make(map[string]int)}
+	games := make([]*mines.Game, 0, 3)
+	for _, d := range [][3]uint16{{9, 9, 10}, {16, 16, 40}, {16, 16, 40}} {
+		g, err := mines.NewGame(d[0], d[1], d[2])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = storeGame(g); err != nil {
+			t.Fatal(err)
+		}
+		s.add(g)
+		games = append(games, g)
+	}
+	win(t, games[0])
+	s.ended(games[0])
+	games[1].End(false)
+	s.ended(games[1])
+	// games that aren't stored never count
+	g, _ := mines.NewGame(9, 9, 10)
+	g.End(false)
+	s.ended(g)
+	obj := s.snapshot()
+	if 3 != obj["created"] || 1 != obj["won"] || 1 != obj["lost"] || "intermediate" != obj["top_difficulty"] {
+		t.Fatalf("stats %v", obj)
+	}
+	if _, ok := obj["average_win_ms"]; !ok {
+		t.Fatalf("stats %v", obj)
+	}
+	// ties go to the first name
+	s.add(games[0])
+	if obj = s.snapshot(); "beginner" != obj["top_difficulty"] {
+		t.Fatalf("tied stats top %v", obj["top_difficulty"])
+	}
+}
+
+func TestStatsEndpoint(t *testing.T) {
+	mux := testMux()
+	before := decode(t, request(mux, "GET", "/stats", nil))
+	uid := createGame(t, mux, url.Values{"difficulty": {"beginner"}})
+	winByRequest(t, mux, uid)
+	request(mux, "POST", "/games/"+createGame(t, mux, url.Values{})+"/forfeit", nil)
+	createGame(t, mux, url.Values{})
+	after := decode(t, request(mux, "GET", "/stats", nil))
+	for key, n := range map[string]float64{"created": 3, "won": 1, "lost": 1, "active": 1} {
+		if got := after[key].(float64) - before[key].(float64); n != got {
+			t.Fatalf("%s went up by %v, want %v", key, got, n)
+		}
+	}
+	if w := request(mux, "POST", "/stats", nil); http.StatusMethodNotAllowed != w.Code {
+		t.Fatalf("post got %d", w.Code)
+	}
+}
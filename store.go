@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jeffchannell/mines-server/mines"
+)
+
+// idempotencyTTL is how long a retried create returns the same game
+const idempotencyTTL = 10 * time.Minute
+
+// idempotent create, remembered until it expires
+type idempotent struct {
+	uid     uuid.UUID
+	expires time.Time
+}
+
+// errStoreFull when no more games can be stored
+var errStoreFull = errors.New("too many games")
+
+var (
+	games   map[uuid.UUID]*mines.Game
+	keys    map[string]idempotent
+	gamesMu sync.RWMutex
+	// maxGames stored at once, unlimited when zero
+	maxGames int
+	// evictFinished games to make room, instead of refusing new ones
+	evictFinished bool
+)
+
+func init() {
+	games = make(map[uuid.UUID]*mines.Game)
+	keys = make(map[string]idempotent)
+}
+
+// storeGame in memory
+func storeGame(g *mines.Game) error {
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+	if err := makeRoom(); err != nil {
+		return err
+	}
+	games[g.UUID()] = g
+	gamesCreated.Inc()
+	stats.add(g)
+	return nil
+}
+
+// storeGameOnce per idempotency key, returning the game stored by an earlier
+// request with the same key instead while it is remembered
+func storeGameOnce(key string, g *mines.Game) (stored *mines.Game, created bool, err error) {
+	now := time.Now()
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+	// forget expired keys as we go
+	for k, v := range keys {
+		if now.After(v.expires) {
+			delete(keys, k)
+		}
+	}
+	if v, ok := keys[key]; ok {
+		if stored, ok := games[v.uid]; ok {
+			return stored, false, nil
+		}
+	}
+	if err := makeRoom(); err != nil {
+		return nil, false, err
+	}
+	games[g.UUID()] = g
+	keys[key] = idempotent{uid: g.UUID(), expires: now.Add(idempotencyTTL)}
+	gamesCreated.Inc()
+	stats.add(g)
+	return g, true, nil
+}
+
+// makeRoom for one more game, evicting the game that finished first if
+// allowed. The caller must hold the write lock.
+func makeRoom() error {
+	if 0 == maxGames || len(games) < maxGames {
+		return nil
+	}
+	if !evictFinished {
+		return errStoreFull
+	}
+	var oldest uuid.UUID
+	var oldestEnd time.Time
+	for uid, g := range games {
+		// a game in use is busy being played, so can't be the oldest
+		if !g.TryLock() {
+			continue
+		}
+		ended := g.EndedAt()
+		g.Unlock()
+		if ended.IsZero() {
+			continue
+		}
+		if uuid.Nil == oldest || ended.Before(oldestEnd) {
+			oldest, oldestEnd = uid, ended
+		}
+	}
+	// active games are never evicted
+	if uuid.Nil == oldest {
+		return errStoreFull
+	}
+	delete(games, oldest)
+	return nil
+}
+
+// deleteGame from memory
+func deleteGame(uid uuid.UUID) {
+	gamesMu.Lock()
+	delete(games, uid)
+	gamesMu.Unlock()
+}
+
+// isStored reports if a game with uid is in memory
+func isStored(uid uuid.UUID) bool {
+	gamesMu.RLock()
+	defer gamesMu.RUnlock()
+	_, ok := games[uid]
+	return ok
+}
+
+// gameCount in memory
+func gameCount() int {
+	gamesMu.RLock()
+	defer gamesMu.RUnlock()
+	return len(games)
+}
+
+// eachGame in memory, each locked while f runs. Games stored or deleted
+// meanwhile may be missed or seen.
+func eachGame(f func(*mines.Game)) {
+	gamesMu.RLock()
+	list := make([]*mines.Game, 0, len(games))
+	for _, g := range games {
+		list = append(list, g)
+	}
+	gamesMu.RUnlock()
+	for _, g := range list {
+		g.Lock()
+		f(g)
+		g.Unlock()
+	}
+}
+
+// lockGame by uuid string, which the caller must unlock when done with it
+func lockGame(uuidStr string) (g *mines.Game, err error) {
+	g, err = getGameByUUIDString(uuidStr)
+	if err != nil {
+		return nil, err
+	}
+	g.Lock()
+	return g, nil
+}
+
+func getGameByUUIDString(uuidStr string) (g *mines.Game, err error) {
+	uid, err := uuid.Parse(uuidStr)
+	if err != nil {
+		return nil, err
+	}
+	gamesMu.RLock()
+	defer gamesMu.RUnlock()
+	if g, ok := games[uid]; ok {
+		return g, nil
+	}
+	return nil, errors.New("invalid Game")
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jeffchannell/mines-server/mines"
+)
+
+// createWithKey posts a create request carrying an idempotency key
+func createWithKey(h http.Handler, key string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "/games/", strings.NewReader(url.Values{"w": {"9"}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("Idempotency-Key", key)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestIdempotentCreate(t *testing.T) {
+	mux := testMux()
+	// keys outlive the test, so each run needs its own
+	key := "retry-" + uuid.NewString()
+	first := createWithKey(mux, key)
+	if http.StatusCreated != first.Code {
+		t.Fatalf("create got %d: %s", first.Code, first.Body.String())
+	}
+	uid := decode(t, first)["uuid"]
+	count := gameCount()
+	again := createWithKey(mux, key)
+	if http.StatusOK != again.Code || uid != decode(t, again)["uuid"] || count != gameCount() {
+		t.Fatalf("retry got %d: %s", again.Code, again.Body.String())
+	}
+	other := createWithKey(mux, "other-"+uuid.NewString())
+	if http.StatusCreated != other.Code || uid == decode(t, other)["uuid"] {
+		t.Fatalf("new key got %d: %s", other.Code, other.Body.String())
+	}
+	// a deleted game is made again
+	request(mux, "DELETE", "/games/"+uid.(string), nil)
+	if w := createWithKey(mux, key); http.StatusCreated != w.Code || uid == decode(t, w)["uuid"] {
+		t.Fatalf("retry of a deleted game got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// emptyStore for the test, putting the stored games back once it is done
+func emptyStore(t *testing.T, limit int, evict bool) {
+	gamesMu.Lock()
+	saved, savedMax, savedEvict := games, maxGames, evictFinished
+	games, maxGames, evictFinished = make(map[uuid.UUID]*mines.Game), limit, evict
+	gamesMu.Unlock()
+	t.Cleanup(func() {
+		gamesMu.Lock()
+		games, maxGames, evictFinished = saved, savedMax, savedEvict
+		gamesMu.Unlock()
+	})
+}
+
+func TestMaxGames(t *testing.T) {
+	emptyStore(t, 2, false)
+	mux := testMux()
+	first := createGame(t, mux, url.Values{})
+	createGame(t, mux, url.Values{})
+	if w := request(mux, "POST", "/games/", url.Values{}); http.StatusServiceUnavailable != w.Code {
+		t.Fatalf("create over the limit got %d", w.Code)
+	}
+	// ended games are kept unless eviction is on
+	request(mux, "POST", "/games/"+first+"/forfeit", url.Values{})
+	if w := request(mux, "POST", "/games/", url.Values{}); http.StatusServiceUnavailable != w.Code {
+		t.Fatalf("create over the limit got %d", w.Code)
+	}
+}
+
+func TestMaxGamesEvictsFinished(t *testing.T) {
+	emptyStore(t, 2, true)
+	mux := testMux()
+	first := createGame(t, mux, url.Values{})
+	second := createGame(t, mux, url.Values{})
+	// active games are never evicted
+	if w := request(mux, "POST", "/games/", url.Values{}); http.StatusServiceUnavailable != w.Code {
+		t.Fatalf("create with only active games got %d", w.Code)
+	}
+	request(mux, "POST", "/games/"+second+"/forfeit", url.Values{})
+	third := createGame(t, mux, url.Values{})
+	if 2 != gameCount() || !stored(t, first) || stored(t, second) || !stored(t, third) {
+		t.Fatal("eviction didn't drop the finished game")
+	}
+}
+
+// stored reports if the game with uid is in memory
+func stored(t *testing.T, uid string) bool {
+	t.Helper()
+	_, err := getGameByUUIDString(uid)
+	return err == nil
+}
+
+func TestBatchCreate(t *testing.T) {
+	emptyStore(t, 4, false)
+	mux := testMux()
+	w := request(mux, "POST", "/games/batch?count=3", url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}})
+	uids, _ := decode(t, w)["uuids"].([]interface{})
+	if http.StatusCreated != w.Code || 3 != len(uids) || 3 != gameCount() {
+		t.Fatalf("batch got %d with %v, stored %d", w.Code, uids, gameCount())
+	}
+	for _, uid := range uids {
+		obj := decode(t, request(mux, "GET", "/games/"+uid.(string), nil))
+		if 9.0 != obj["width"] || 10.0 != obj["mines"] {
+			t.Fatalf("batch game %v", obj)
+		}
+	}
+	// a batch that doesn't fit stores none of its games
+	if w = request(mux, "POST", "/games/batch", url.Values{"count": {"2"}}); http.StatusServiceUnavailable != w.Code || 3 != gameCount() {
+		t.Fatalf("batch over the limit got %d, stored %d", w.Code, gameCount())
+	}
+	for _, count := range []string{"0", strconv.Itoa(maxBatch + 1)} {
+		if w = request(mux, "POST", "/games/batch", url.Values{"count": {count}}); http.StatusBadRequest != w.Code {
+			t.Fatalf("batch of %s got %d", count, w.Code)
+		}
+	}
+}
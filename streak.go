@@ -0,0 +1,53 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/jeffchannell/mines-server/mines"
+)
+
+// streak of consecutive results for a player
+type streak struct {
+	Name   string `json:"name"`
+	Result string `json:"result"` // won or lost
+	Count  int    `json:"count"`
+}
+
+// streakTracker of every named player
+type streakTracker struct {
+	mu      sync.Mutex
+	players map[string]streak
+}
+
+var streaks = &streakTracker{players: make(map[string]streak)}
+
+func init() {
+	mines.OnEnd(streaks.record)
+}
+
+// record the result of an ended game, ignoring unnamed players and games not
+// stored here, like resumed ones
+func (t *streakTracker) record(g *mines.Game) {
+	name := g.PlayerName()
+	if "" == name || !isStored(g.UUID()) {
+		return
+	}
+	result := g.Status()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.players[name]
+	// the opposite result starts a new streak
+	if result != s.Result {
+		s = streak{Name: name, Result: result}
+	}
+	s.Count++
+	t.players[name] = s
+}
+
+// get the streak of a player
+func (t *streakTracker) get(name string) (streak, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.players[name]
+	return s, ok
+}
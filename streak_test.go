@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/jeffchannell/mines-server/mines"
+)
+
+// streakOf the player, as the endpoint shows it
+func streakOf(t *testing.T, h http.Handler, name string) map[string]interface{} {
+	t.Helper()
+	w := request(h, "GET", "/players/"+name+"/streak", nil)
+	if http.StatusOK != w.Code {
+		t.Fatalf("streak of %s got %d: %s", name, w.Code, w.Body.String())
+	}
+	return decode(t, w)
+}
+
+func TestStreaks(t *testing.T) {
+	mux := testMux()
+	form := url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}, "name": {"streaker"}}
+	winByRequest(t, mux, createGame(t, mux, form))
+	winByRequest(t, mux, createGame(t, mux, form))
+	if s := streakOf(t, mux, "streaker"); "won" != s["result"] || 2.0 != s["count"] {
+		t.Fatalf("after two wins %v", s)
+	}
+	// a loss starts over
+	request(mux, "POST", "/games/"+createGame(t, mux, form)+"/forfeit", nil)
+	if s := streakOf(t, mux, "streaker"); "lost" != s["result"] || 1.0 != s["count"] {
+		t.Fatalf("after a loss %v", s)
+	}
+	// games that aren't stored here don't count
+	g, err := mines.NewGame(9, 9, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.SetPlayerName("streaker")
+	g.End(false)
+	if s := streakOf(t, mux, "streaker"); 1.0 != s["count"] {
+		t.Fatalf("unstored loss counted %v", s)
+	}
+}
+
+func TestStreakNotFound(t *testing.T) {
+	mux := testMux()
+	for _, target := range []string{"/players/nobody-ever/streak", "/players/streaker"} {
+		if w := request(mux, "GET", target, nil); http.StatusNotFound != w.Code {
+			t.Fatalf("%s got %d", target, w.Code)
+		}
+	}
+	if w := request(mux, "POST", "/players/streaker/streak", nil); http.StatusMethodNotAllowed != w.Code {
+		t.Fatalf("post got %d", w.Code)
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// fieldKind of value a request field must hold
+type fieldKind int
+
+const (
+	fieldUint16 fieldKind = iota // a number from 0 to 65535
+	fieldInt64                   // any 64-bit number
+	fieldFloat                   // a decimal number
+	fieldBool                    // 0 or 1
+	fieldString                  // anything, or one of the allowed values
+)
+
+// field a request may, or must, carry
+type field struct {
+	name     string
+	kind     fieldKind
+	required bool
+	allowed  []string // values a string field is limited to, if any
+}
+
+// boardFields describe the board a create request asks for
+var boardFields = []field{
+	{name: "w", kind: fieldUint16},
+	{name: "h", kind: fieldUint16},
+	{name: "m", kind: fieldUint16},
+	{name: "density", kind: fieldFloat},
+	{name: "difficulty", kind: fieldString, allowed: []string{"beginner", "intermediate", "expert"}},
+	{name: "q", kind: fieldBool},
+	{name: "chord", kind: fieldBool},
+	{name: "secure", kind: fieldBool},
+	{name: "wrap", kind: fieldBool},
+	{name: "topology", kind: fieldString, allowed: []string{"", "square", "hex"}},
+	{name: "zero", kind: fieldBool},
+	{name: "turns", kind: fieldBool},
+	{name: "seed", kind: fieldInt64},
+	{name: "cascade", kind: fieldUint16},
+	{name: "min_interval", kind: fieldUint16},
+	{name: "nocluster", kind: fieldBool},
+	{name: "force", kind: fieldBool},
+}
+
+// coordFields name a tile
+var coordFields = []field{
+	{name: "x", kind: fieldUint16, required: true},
+	{name: "y", kind: fieldUint16, required: true},
+}
+
+// fields checked for each endpoint
+var (
+	createFields = append([]field{{name: "name", kind: fieldString}}, boardFields...)
+	batchFields  = append([]field{{name: "count", kind: fieldUint16, required: true}}, boardFields...)
+	// matches deal both boards from a seed of their own
+	matchFields = without(boardFields, "secure", "seed")
+	// previews are always seeded, so can't be secure
+	boardStatsFields = without(boardFields, "secure")
+	importFields     = []field{
+		{name: "code", kind: fieldString, required: true},
+		{name: "name", kind: fieldString},
+	}
+	// a move is optional when resuming, but needs both coordinates
+	resumeFields = []field{
+		{name: "blob", kind: fieldString, required: true},
+		{name: "x", kind: fieldUint16},
+		{name: "y", kind: fieldUint16},
+		{name: "flag", kind: fieldBool},
+	}
+	// gameFields are read by every POST to a game
+	gameFields = []field{{name: "name", kind: fieldString}}
+	moveFields = append([]field{{name: "flag", kind: fieldBool}}, coordFields...)
+	// clickFields are a move and a game's fields, checked before the click
+	clickFields = append(append([]field{{name: "player", kind: fieldString}}, moveFields...), gameFields...)
+)
+
+// viewParams are read from the query by viewOf, and may come with any request
+var viewParams = map[string]bool{
+	"flags":   true,
+	"format":  true,
+	"ts":      true,
+	"symbols": true,
+	"timings": true,
+	"delta":   true,
+}
+
+// postFields a POST to a game may hold, by the action in its path. Anything
+// else is a click, which validate checks the same body as.
+func postFields(action string) []field {
+	switch action {
+	case "auto", "blob", "join", "continue", "autoflag", "forfeit", "restart", "pause", "resume", "moves", "flags":
+		return gameFields
+	}
+	return clickFields
+}
+
+// without the named fields
+func without(fields []field, names ...string) (kept []field) {
+	skip := make(map[string]bool)
+	for _, n := range names {
+		skip[n] = true
+	}
+	for _, f := range fields {
+		if !skip[f.name] {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// parse a field from form, returning its value, or nil if it is absent, and
+// any problem with it
+func (f field) parse(form url.Values) (interface{}, string) {
+	if !form.Has(f.name) {
+		if f.required {
+			return nil, "required"
+		}
+		return nil, ""
+	}
+	v := form.Get(f.name)
+	switch f.kind {
+	case fieldUint16:
+		n, err := strconv.ParseUint(v, 10, 16)
+		if err != nil {
+			return nil, "must be a number from 0 to 65535"
+		}
+		return uint16(n), ""
+	case fieldInt64:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, "must be a whole number"
+		}
+		return n, ""
+	case fieldFloat:
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, "must be a number"
+		}
+		return n, ""
+	case fieldBool:
+		if "0" != v && "1" != v {
+			return nil, "must be 0 or 1"
+		}
+		return "1" == v, ""
+	}
+	if 0 == len(f.allowed) {
+		return v, ""
+	}
+	for _, a := range f.allowed {
+		if a == v {
+			return v, ""
+		}
+	}
+	return nil, "is not an allowed value"
+}
+
+// fieldValues parsed from a request, holding only the fields it sent
+type fieldValues map[string]interface{}
+
+// has reports if the field was sent
+func (v fieldValues) has(name string) bool {
+	_, ok := v[name]
+	return ok
+}
+
+// uint16 field, or def when it is absent
+func (v fieldValues) uint16(name string, def uint16) uint16 {
+	if n, ok := v[name].(uint16); ok {
+		return n
+	}
+	return def
+}
+
+// int64 field, or def when it is absent
+func (v fieldValues) int64(name string, def int64) int64 {
+	if n, ok := v[name].(int64); ok {
+		return n
+	}
+	return def
+}
+
+// float field, or def when it is absent
+func (v fieldValues) float(name string, def float64) float64 {
+	if n, ok := v[name].(float64); ok {
+		return n
+	}
+	return def
+}
+
+// bool field, or def when it is absent
+func (v fieldValues) bool(name string, def bool) bool {
+	if b, ok := v[name].(bool); ok {
+		return b
+	}
+	return def
+}
+
+// string field, or def when it is absent
+func (v fieldValues) string(name string, def string) string {
+	if s, ok := v[name].(string); ok {
+		return s
+	}
+	return def
+}
+
+// checkFields of form, returning the values sent and every problem found,
+// keyed by prefix and field name, including any field that isn't known
+func checkFields(form url.Values, fields []field, prefix string, problems map[string]string) fieldValues {
+	values := make(fieldValues)
+	known := make(map[string]bool)
+	for _, f := range fields {
+		known[f.name] = true
+		v, p := f.parse(form)
+		if "" != p {
+			problems[prefix+f.name] = p
+		} else if nil != v {
+			values[f.name] = v
+		}
+	}
+	for name := range form {
+		if !known[name] {
+			problems[prefix+name] = "is not a known field"
+		}
+	}
+	return values
+}
+
+// parseFields of the request form, writing every offending field to the
+// client at once
+func parseFields(w http.ResponseWriter, r *http.Request, fields []field) (fieldValues, bool) {
+	// the query can also ask for a view of the game, which isn't a field
+	form := make(url.Values)
+	for name, v := range r.Form {
+		if !viewParams[name] {
+			form[name] = v
+		}
+	}
+	problems := make(map[string]string)
+	values := checkFields(form, fields, "", problems)
+	if 0 < len(problems) {
+		writeInvalidFields(w, problems)
+		return nil, false
+	}
+	return values, true
+}
+
+// parseMoves from a JSON array of move objects, checking each against
+// moveFields and writing every offending field to the client at once
+func parseMoves(w http.ResponseWriter, r *http.Request) ([]fieldValues, bool) {
+	var items []map[string]interface{}
+	d := json.NewDecoder(r.Body)
+	d.UseNumber()
+	if err := d.Decode(&items); err != nil {
+		jsonError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+	problems := make(map[string]string)
+	moves := make([]fieldValues, len(items))
+	for i, item := range items {
+		prefix := fmt.Sprintf("moves[%d].", i)
+		form := make(url.Values)
+		for k, v := range item {
+			s, ok := formValue(v)
+			if !ok {
+				problems[prefix+k] = "is not a string, number or boolean"
+				continue
+			}
+			form.Set(k, s)
+		}
+		moves[i] = checkFields(form, moveFields, prefix, problems)
+	}
+	if 0 < len(problems) {
+		writeInvalidFields(w, problems)
+		return nil, false
+	}
+	return moves, true
+}
+
+// writeInvalidFields to the client
+func writeInvalidFields(w http.ResponseWriter, problems map[string]string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json, err := json.Marshal(map[string]interface{}{
+		"error":  "invalid fields",
+		"fields": problems,
+	})
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	w.Write(json)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCreateRejectsBadNumbers(t *testing.T) {
+	mux := testMux()
+	fields := invalidFields(t, request(mux, "POST", "/games/", url.Values{"w": {"abc"}, "h": {"-1"}, "m": {"1.5"}, "seed": {"x"}}))
+	for _, name := range []string{"w", "h", "m", "seed"} {
+		if _, ok := fields[name]; !ok {
+			t.Errorf("bad %s not reported: %v", name, fields)
+		}
+	}
+	if _, ok := invalidFields(t, request(mux, "POST", "/games/", url.Values{"w": {"70000"}}))["w"]; !ok {
+		t.Fatal("width over 65535 not reported")
+	}
+	if _, ok := invalidFields(t, request(mux, "POST", "/games/", url.Values{"q": {"maybe"}}))["q"]; !ok {
+		t.Fatal("bad flag not reported")
+	}
+}
+
+func TestDensitySafeguard(t *testing.T) {
+	mux := testMux()
+	if w := request(mux, "POST", "/games/", url.Values{"w": {"10"}, "h": {"10"}, "m": {"50"}}); http.StatusCreated != w.Code {
+		t.Fatalf("board at the density limit got %d: %s", w.Code, w.Body.String())
+	}
+	w := request(mux, "POST", "/games/", url.Values{"w": {"10"}, "h": {"10"}, "m": {"51"}})
+	if http.StatusBadRequest != w.Code || !strings.Contains(w.Body.String(), "force=1") {
+		t.Fatalf("board over the density limit got %d: %s", w.Code, w.Body.String())
+	}
+	if w = request(mux, "POST", "/games/", url.Values{"w": {"10"}, "h": {"10"}, "m": {"51"}, "force": {"1"}}); http.StatusCreated != w.Code {
+		t.Fatalf("forced board got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestTooManyMinesReportsMax(t *testing.T) {
+	mux := testMux()
+	w := request(mux, "POST", "/games/", url.Values{"w": {"20"}, "h": {"20"}, "m": {"400"}, "force": {"1"}})
+	if obj := decode(t, w); http.StatusBadRequest != w.Code || 398.0 != obj["max_mines"] || !strings.Contains(obj["error"].(string), "398") {
+		t.Fatalf("too many mines got %d: %v", w.Code, obj)
+	}
+	// other bad boards have no maximum to report
+	w = request(mux, "POST", "/games/", url.Values{"topology": {"triangle"}})
+	if _, ok := decode(t, w)["max_mines"]; ok || http.StatusBadRequest != w.Code {
+		t.Fatalf("unknown topology got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestFieldErrors(t *testing.T) {
+	mux := testMux()
+	uid := createGame(t, mux, url.Values{"w": {"9"}, "h": {"9"}, "m": {"10"}})
+	if _, ok := invalidFields(t, request(mux, "POST", "/games/"+uid, url.Values{"x": {"1"}}))["y"]; !ok {
+		t.Fatal("click without y not reported")
+	}
+	if _, ok := invalidFields(t, request(mux, "POST", "/games/"+uid+"/validate", url.Values{"x": {"a"}, "y": {"1"}}))["x"]; !ok {
+		t.Fatal("bad validate x not reported")
+	}
+	// typos are reported rather than ignored
+	if p := invalidFields(t, request(mux, "POST", "/games/"+uid, url.Values{"x": {"1"}, "y": {"1"}, "flagg": {"1"}}))["flagg"]; "is not a known field" != p {
+		t.Fatalf("unknown click field reported as %v", p)
+	}
+	if _, ok := invalidFields(t, request(mux, "POST", "/games/"+uid+"/forfeit", url.Values{"x": {"1"}}))["x"]; !ok {
+		t.Fatal("coordinates on a forfeit not reported")
+	}
+	fields := invalidFields(t, postJSON(mux, "/games/"+uid+"/moves", `[{"x":1,"y":1},{"x":-1,"y":0}]`))
+	if _, ok := fields["moves[1].x"]; !ok || 1 != len(fields) {
+		t.Fatalf("bad move not reported alone: %v", fields)
+	}
+	if _, ok := invalidFields(t, postJSON(mux, "/games/"+uid+"/moves", `[{"x":{},"y":0}]`))["moves[0].x"]; !ok {
+		t.Fatal("object move not reported")
+	}
+	blob, _ := decode(t, request(mux, "POST", "/games/"+uid+"/blob", url.Values{}))["blob"].(string)
+	if _, ok := invalidFields(t, request(mux, "POST", "/games/resume", url.Values{"blob": {blob}, "x": {"1"}}))["y"]; !ok {
+		t.Fatal("resume with half a move not reported")
+	}
+}
+
+func TestCreateFieldErrors(t *testing.T) {
+	mux := testMux()
+	fields := invalidFields(t, request(mux, "POST", "/games/", url.Values{"difficulty": {"hard"}, "topology": {"tri"}, "colour": {"red"}}))
+	for _, name := range []string{"difficulty", "topology", "colour"} {
+		if _, ok := fields[name]; !ok {
+			t.Errorf("bad %s not reported: %v", name, fields)
+		}
+	}
+	if p := invalidFields(t, request(mux, "POST", "/games/batch", url.Values{}))["count"]; "required" != p {
+		t.Fatalf("batch without count reported as %v", p)
+	}
+	if _, ok := invalidFields(t, request(mux, "POST", "/games/import", url.Values{}))["code"]; !ok {
+		t.Fatal("import without code not reported")
+	}
+	// JSON bodies are held to the same fields
+	if _, ok := invalidFields(t, postJSON(mux, "/games/", `{"w":9,"h":9,"mines":10}`))["mines"]; !ok {
+		t.Fatal("unknown JSON field not reported")
+	}
+	if _, ok := invalidFields(t, postJSON(mux, "/games/import", `{"code":["a"]}`))["code"]; !ok {
+		t.Fatal("JSON import with a list for a code not reported")
+	}
+}